@@ -2,10 +2,11 @@ package api
 
 import (
 	"encoding/json"
-	"github.com/GreatGodApollo/ala/models"
+	"github.com/GreatGodApollo/alp"
 	"github.com/go-resty/resty/v2"
 )
 
+// Deprecated: use Client.All.
 func GetAll(c *resty.Client, baseurl, username, password, product string) (interface{}, error) {
 	resp, err := c.R().
 		SetBasicAuth(username, password).
@@ -17,14 +18,14 @@ func GetAll(c *resty.Client, baseurl, username, password, product string) (inter
 	}
 
 	if resp.StatusCode()/100 == 2 {
-		var respBody models.Licenses
+		var respBody alp.Licenses
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
 		}
 		return respBody, nil
 	} else {
-		var respBody models.BasicResponse
+		var respBody alp.BasicResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
@@ -33,10 +34,16 @@ func GetAll(c *resty.Client, baseurl, username, password, product string) (inter
 	}
 }
 
+// Deprecated: use Client.Create.
 func CreateLicense(c *resty.Client, baseurl, username, password, email, product string) (interface{}, error) {
+	return CreateLicenseRequest(c, baseurl, username, password, alp.LicenseRequest{Email: email, Product: product})
+}
+
+// Deprecated: use Client.Create.
+func CreateLicenseRequest(c *resty.Client, baseurl, username, password string, req alp.LicenseRequest) (interface{}, error) {
 	resp, err := c.R().
 		SetBasicAuth(username, password).
-		SetBody(models.LicenseRequest{Email: email, Product: product}).
+		SetBody(req).
 		SetHeader("Accept", "application/json").
 		Post(baseurl + "/api/v1/create")
 
@@ -45,14 +52,14 @@ func CreateLicense(c *resty.Client, baseurl, username, password, email, product
 	}
 
 	if resp.StatusCode()/100 == 2 {
-		var respBody models.LicenseResponse
+		var respBody alp.LicenseResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
 		}
 		return respBody, nil
 	} else {
-		var respBody models.BasicResponse
+		var respBody alp.BasicResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
@@ -61,11 +68,12 @@ func CreateLicense(c *resty.Client, baseurl, username, password, email, product
 	}
 }
 
+// Deprecated: use Client.Get.
 func GetSpecific(c *resty.Client, baseurl, username, password, key string) (interface{}, error) {
 	resp, err := c.R().
 		SetBasicAuth(username, password).
 		SetHeader("Accept", "application/json").
-		SetBody(models.BasicRequest{Key: key}).
+		SetBody(alp.BasicRequest{Key: key}).
 		Post(baseurl + "/api/v1/specific")
 
 	if err != nil {
@@ -73,14 +81,14 @@ func GetSpecific(c *resty.Client, baseurl, username, password, key string) (inte
 	}
 
 	if resp.StatusCode()/100 == 2 {
-		var respBody models.License
+		var respBody alp.License
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
 		}
 		return respBody, nil
 	} else {
-		var respBody models.BasicResponse
+		var respBody alp.BasicResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
@@ -89,28 +97,29 @@ func GetSpecific(c *resty.Client, baseurl, username, password, key string) (inte
 	}
 }
 
+// Deprecated: use Client.Invalidate.
 func InvalidateLicense(c *resty.Client, baseurl, username, password, key string) (interface{}, error) {
-	var result models.BasicResponse
+	var result alp.BasicResponse
 	resp, err := c.R().
 		SetBasicAuth(username, password).
-		SetBody(models.BasicRequest{Key: key}).
+		SetBody(alp.BasicRequest{Key: key}).
 		SetHeader("Accept", "application/json").
 		SetResult(&result).
 		Post(baseurl + "/api/v1/invalidate")
 
 	if err != nil {
-		return models.BasicResponse{}, err
+		return alp.BasicResponse{}, err
 	}
 
 	if resp.StatusCode()/100 == 2 {
-		var respBody models.LicenseResponse
+		var respBody alp.LicenseResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
 		}
 		return respBody, nil
 	} else {
-		var respBody models.BasicResponse
+		var respBody alp.BasicResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return nil, err
@@ -120,10 +129,20 @@ func InvalidateLicense(c *resty.Client, baseurl, username, password, key string)
 
 }
 
+// Deprecated: use Client.Valid.
 func CheckValidity(c *resty.Client, baseurl, key, product string) bool {
+	return checkRequestValidity(c, baseurl, alp.CheckRequest{Key: key, Product: product})
+}
+
+// Deprecated: use Client.Check.
+func CheckVersionValidity(c *resty.Client, baseurl, key, product, version string) bool {
+	return checkRequestValidity(c, baseurl, alp.CheckRequest{Key: key, Product: product, Version: version})
+}
+
+func checkRequestValidity(c *resty.Client, baseurl string, req alp.CheckRequest) bool {
 	resp, err := c.R().
 		SetHeader("Accept", "application/json").
-		SetBody(models.CheckRequest{Key: key, Product: product}).
+		SetBody(req).
 		Post(baseurl + "/license/check")
 
 	if err != nil {
@@ -131,12 +150,12 @@ func CheckValidity(c *resty.Client, baseurl, key, product string) bool {
 	}
 
 	if resp.StatusCode()/100 == 2 {
-		var respBody models.LicenseResponse
+		var respBody alp.LicenseResponse
 		err = json.Unmarshal(resp.Body(), &respBody)
 		if err != nil {
 			return false
 		}
-		if respBody.Status == "valid" {
+		if respBody.Status == alp.StatusValid {
 			return true
 		}
 		return false
@@ -144,3 +163,80 @@ func CheckValidity(c *resty.Client, baseurl, key, product string) bool {
 		return false
 	}
 }
+
+// Deprecated: use Client.CreateAPIKey.
+func CreateAPIKey(c *resty.Client, baseurl, username, password string, req alp.APIKeyRequest) (interface{}, error) {
+	resp, err := c.R().
+		SetBasicAuth(username, password).
+		SetBody(req).
+		SetHeader("Accept", "application/json").
+		Post(baseurl + "/api/v1/keys/create")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode()/100 == 2 {
+		var respBody alp.APIKeyResponse
+		err = json.Unmarshal(resp.Body(), &respBody)
+		if err != nil {
+			return nil, err
+		}
+		return respBody, nil
+	} else {
+		var respBody alp.BasicResponse
+		err = json.Unmarshal(resp.Body(), &respBody)
+		if err != nil {
+			return nil, err
+		}
+		return respBody, nil
+	}
+}
+
+// Deprecated: use Client.APIKeys.
+func GetAPIKeys(c *resty.Client, baseurl, username, password string) (interface{}, error) {
+	resp, err := c.R().
+		SetBasicAuth(username, password).
+		SetHeader("Accept", "application/json").
+		Get(baseurl + "/api/v1/keys/all")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode()/100 == 2 {
+		var respBody alp.APIKeys
+		err = json.Unmarshal(resp.Body(), &respBody)
+		if err != nil {
+			return nil, err
+		}
+		return respBody, nil
+	} else {
+		var respBody alp.BasicResponse
+		err = json.Unmarshal(resp.Body(), &respBody)
+		if err != nil {
+			return nil, err
+		}
+		return respBody, nil
+	}
+}
+
+// Deprecated: use Client.RevokeAPIKey.
+func RevokeAPIKey(c *resty.Client, baseurl, username, password string, id int) (alp.BasicResponse, error) {
+	var respBody alp.BasicResponse
+	resp, err := c.R().
+		SetBasicAuth(username, password).
+		SetBody(alp.RevokeRequest{Id: id}).
+		SetHeader("Accept", "application/json").
+		Post(baseurl + "/api/v1/keys/revoke")
+
+	if err != nil {
+		return alp.BasicResponse{}, err
+	}
+
+	err = json.Unmarshal(resp.Body(), &respBody)
+	if err != nil {
+		return alp.BasicResponse{}, err
+	}
+	return respBody, nil
+}
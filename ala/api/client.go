@@ -0,0 +1,324 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Client talks to a license server. Create one with NewClient and reuse it.
+type Client struct {
+	rest      *resty.Client
+	endpoints []string
+	current   int32
+	retry     RetryPolicy
+	onAttempt func(Attempt)
+	dryRun    bool
+	username  string
+	password  string
+	apiKey    string
+	publicKey ed25519.PublicKey
+}
+
+type Option func(*Client)
+
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.endpoints = []string{baseURL}
+	}
+}
+
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.rest.SetTimeout(timeout)
+	}
+}
+
+// WithHTTPClient makes the client send requests through hc. It replaces any
+// timeout set before it.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.rest = resty.NewWithClient(hc)
+	}
+}
+
+// WithPublicKey pins the server's signing key. Checks then fail unless the
+// response is signed by it.
+func WithPublicKey(pub ed25519.PublicKey) Option {
+	return func(c *Client) {
+		c.publicKey = pub
+	}
+}
+
+// PinnedKey returns the key set with WithPublicKey, or nil.
+func (c *Client) PinnedKey() ed25519.PublicKey {
+	return c.publicKey
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		rest:      resty.New(),
+		endpoints: []string{"http://localhost:8080"},
+		retry:     DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// DryRun returns a copy of the client whose requests ask the server to
+// validate them without changing anything. Mutating calls then answer with
+// status "dry_run" instead of doing their work, and fail with
+// ErrDryRunIgnored when the server answers as if it did it.
+func (c *Client) DryRun() *Client {
+	cp := *c
+	cp.current = atomic.LoadInt32(&c.current)
+	cp.dryRun = true
+	return &cp
+}
+
+func (c *Client) Create(ctx context.Context, req alp.LicenseRequest) (alp.LicenseResponse, error) {
+	var resp alp.LicenseResponse
+	err := c.do(ctx, http.MethodPost, "/api/v1/create", req, &resp)
+	return resp, err
+}
+
+func (c *Client) Get(ctx context.Context, key string) (alp.License, error) {
+	var resp alp.License
+	err := c.do(ctx, http.MethodPost, "/api/v1/specific", alp.BasicRequest{Key: key}, &resp)
+	return resp, err
+}
+
+func (c *Client) All(ctx context.Context, product string) ([]alp.License, error) {
+	var resp alp.Licenses
+	err := c.do(ctx, http.MethodGet, "/api/v1/all/"+url.PathEscape(product), nil, &resp)
+	return resp.Licenses, err
+}
+
+// Licenses lists every license for product, invalid ones included, oldest
+// first.
+func (c *Client) Licenses(ctx context.Context, product string) ([]alp.License, error) {
+	var resp alp.Licenses
+	err := c.do(ctx, http.MethodGet, "/api/v2/licenses?product="+url.QueryEscape(product), nil, &resp)
+	return resp.Licenses, err
+}
+
+// Products lists the products that have licenses.
+func (c *Client) Products(ctx context.Context) ([]string, error) {
+	var resp alp.Products
+	err := c.do(ctx, http.MethodGet, "/api/v1/products", nil, &resp)
+	return resp.Products, err
+}
+
+// Invalidate invalidates key. Invalidating an already invalid license is
+// not an error; the response's Status tells the two apart.
+func (c *Client) Invalidate(ctx context.Context, key string) (alp.LicenseResponse, error) {
+	return c.licenseAction(ctx, "/api/v1/invalidate", key)
+}
+
+// Suspend suspends key so that checks fail until it is resumed. Suspending
+// an already suspended license is not an error; its Status is "unchanged".
+func (c *Client) Suspend(ctx context.Context, key string) (alp.LicenseResponse, error) {
+	return c.licenseAction(ctx, "/api/v1/suspend", key)
+}
+
+// Resume lifts a suspension placed by Suspend.
+func (c *Client) Resume(ctx context.Context, key string) (alp.LicenseResponse, error) {
+	return c.licenseAction(ctx, "/api/v1/resume", key)
+}
+
+// History lists what has been done to key, oldest first.
+func (c *Client) History(ctx context.Context, key string) ([]alp.HistoryEntry, error) {
+	var resp alp.History
+	err := c.do(ctx, http.MethodPost, "/api/v1/history", alp.BasicRequest{Key: key}, &resp)
+	return resp.History, err
+}
+
+func (c *Client) licenseAction(ctx context.Context, path, key string) (alp.LicenseResponse, error) {
+	var resp alp.LicenseResponse
+	err := c.do(ctx, http.MethodPost, path, alp.BasicRequest{Key: key}, &resp)
+	return resp, err
+}
+
+// Check asks the server about req. When a public key is pinned the request
+// carries a fresh nonce and the signed response is verified.
+func (c *Client) Check(ctx context.Context, req alp.CheckRequest) (alp.LicenseResponse, error) {
+	if c.publicKey != nil {
+		nonce, err := GenerateNonce()
+		if err != nil {
+			return alp.LicenseResponse{}, err
+		}
+		req.Nonce = nonce
+	}
+
+	var resp alp.LicenseResponse
+	if err := c.do(ctx, http.MethodPost, "/license/check", req, &resp); err != nil {
+		return alp.LicenseResponse{}, err
+	}
+	if c.publicKey != nil {
+		if err := VerifyCheckResponse(c.publicKey, req, resp, time.Now()); err != nil {
+			return alp.LicenseResponse{}, err
+		}
+	}
+	return resp, nil
+}
+
+// Valid reports whether key is a valid license for product. A license that
+// doesn't exist is not an error.
+func (c *Client) Valid(ctx context.Context, key, product string) (bool, error) {
+	resp, err := c.Check(ctx, alp.CheckRequest{Key: key, Product: product})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return resp.Status == alp.StatusValid, nil
+}
+
+func (c *Client) PublicKey(ctx context.Context) (ed25519.PublicKey, error) {
+	var resp struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/license/pubkey", nil, &resp); err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(resp.PublicKey)
+}
+
+func (c *Client) CreateAPIKey(ctx context.Context, req alp.APIKeyRequest) (alp.APIKeyResponse, error) {
+	var resp alp.APIKeyResponse
+	err := c.do(ctx, http.MethodPost, "/api/v1/keys/create", req, &resp)
+	return resp, err
+}
+
+func (c *Client) APIKeys(ctx context.Context) ([]alp.APIKey, error) {
+	var resp alp.APIKeys
+	err := c.do(ctx, http.MethodGet, "/api/v1/keys/all", nil, &resp)
+	return resp.Keys, err
+}
+
+func (c *Client) RevokeAPIKey(ctx context.Context, id int) (alp.BasicResponse, error) {
+	var resp alp.BasicResponse
+	err := c.do(ctx, http.MethodPost, "/api/v1/keys/revoke", alp.RevokeRequest{Id: id}, &resp)
+	return resp, err
+}
+
+// do sends a request, retrying and failing over between endpoints as the
+// retry policy allows. The endpoint that last answered is tried first.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	idem := idempotent(method, path)
+	start := int(atomic.LoadInt32(&c.current))
+
+	var err error
+	for attempt := 1; attempt <= c.retry.MaxAttempts; attempt++ {
+		i := (start + attempt - 1) % len(c.endpoints)
+		var header http.Header
+		header, err = c.send(ctx, method, c.endpoints[i], path, body, out)
+
+		info := Attempt{Method: method, Endpoint: c.endpoints[i], Path: path, Number: attempt, Err: err}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			info.StatusCode = apiErr.StatusCode
+		} else if err == nil {
+			info.StatusCode = http.StatusOK
+		}
+
+		again := err != nil && ctx.Err() == nil && attempt < c.retry.MaxAttempts && retryable(idem, err)
+		if again {
+			info.Retry = retryAfter(header)
+			if info.Retry > c.retry.MaxDelay {
+				info.Retry = c.retry.MaxDelay
+			}
+			if info.Retry == 0 {
+				info.Retry = c.retry.backoff(attempt)
+			}
+		}
+		if c.onAttempt != nil {
+			c.onAttempt(info)
+		}
+
+		if err == nil || (apiErr != nil && apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests) {
+			atomic.StoreInt32(&c.current, int32(i))
+		}
+		if !again {
+			return err
+		}
+		if sleepErr := sleep(ctx, info.Retry); sleepErr != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, endpoint, path string, body, out interface{}) (http.Header, error) {
+	req := c.rest.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/json")
+	if c.apiKey != "" {
+		req.SetAuthToken(c.apiKey)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if body != nil {
+		req.SetBody(body)
+	}
+	if c.dryRun {
+		req.SetQueryParam("dry_run", "true")
+	}
+
+	resp, err := req.Execute(method, endpoint+path)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode()/100 != 2 {
+		apiErr := &APIError{StatusCode: resp.StatusCode(), RetryAfter: retryAfter(resp.Header())}
+		var respBody alp.ErrorResponse
+		if json.Unmarshal(resp.Body(), &respBody) == nil {
+			apiErr.Status = respBody.Status
+			apiErr.ErrorCode = respBody.Error
+			apiErr.Message = respBody.Message
+		}
+		return resp.Header(), apiErr
+	}
+	if c.dryRun && !idempotent(method, path) && !dryRunHonoured(resp.Body()) {
+		return resp.Header(), ErrDryRunIgnored
+	}
+	return resp.Header(), json.Unmarshal(resp.Body(), out)
+}
+
+// dryRunHonoured reports whether a response to a dry run says nothing was
+// changed: either what would have been, or that there was nothing to do.
+func dryRunHonoured(body []byte) bool {
+	var resp alp.BasicResponse
+	if json.Unmarshal(body, &resp) != nil {
+		return false
+	}
+	switch resp.Status {
+	case alp.StatusDryRun, alp.StatusUnchanged, alp.StatusInvalid:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrBadRequest   = errors.New("bad request")
+	ErrConflict     = errors.New("conflict")
+	ErrInvalidKey   = errors.New("invalid license key")
+	// ErrDryRunIgnored means a dry run's request was carried out by a
+	// server that doesn't support dry runs for it.
+	ErrDryRunIgnored = errors.New("server ignored the dry run and may have made the change")
+)
+
+// APIError is returned by Client when the server answers with an error.
+// It matches the Err* values with errors.Is.
+type APIError struct {
+	StatusCode int
+	Status     string
+	// ErrorCode is one of the alp.Error* codes, when the server sent one.
+	ErrorCode string
+	Message   string
+	// RetryAfter is the wait the server asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrInvalidKey:
+		return e.ErrorCode == alp.ErrorInvalidKeyFormat
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how often and how patiently a Client retries. Each
+// retry goes to the next endpoint, so with several endpoints a retry is
+// also a failover.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first. One
+	// disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// MaxDelay caps the wait between tries, including one asked for with
+	// Retry-After.
+	MaxDelay time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// Attempt describes one try of a request, for logging.
+type Attempt struct {
+	Method     string
+	Endpoint   string
+	Path       string
+	Number     int
+	StatusCode int
+	Err        error
+	// Retry is how long the client waits before the next try. Zero means
+	// there won't be one.
+	Retry time.Duration
+}
+
+// WithEndpoints sets the servers the client talks to, in order of
+// preference. It replaces WithBaseURL.
+func WithEndpoints(urls ...string) Option {
+	return func(c *Client) {
+		if len(urls) > 0 {
+			c.endpoints = append([]string(nil), urls...)
+		}
+	}
+}
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		if p.MaxAttempts < 1 {
+			p.MaxAttempts = 1
+		}
+		c.retry = p
+	}
+}
+
+// WithAttemptHook calls fn after every try of every request.
+func WithAttemptHook(fn func(Attempt)) Option {
+	return func(c *Client) {
+		c.onAttempt = fn
+	}
+}
+
+// These POST routes only read. Invalidate, suspend and resume are left out:
+// repeating one that went through would report the license as already in
+// that state, hiding that the first try did the work.
+var idempotentPaths = map[string]bool{
+	"/license/check":   true,
+	"/api/v1/specific": true,
+	"/api/v1/history":  true,
+}
+
+func idempotent(method, path string) bool {
+	return method == http.MethodGet || idempotentPaths[path]
+}
+
+// retryable reports whether a failed try may be repeated. Requests that
+// aren't idempotent are only repeated when they never reached a server.
+func retryable(idempotent bool, err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return idempotent && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500)
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var netErr net.Error
+	return idempotent && errors.As(err, &netErr)
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt-1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// retryAfter reads a Retry-After header given in seconds or as a date.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"context"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/ala/licensetest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClientWaitsForRetryAfter(t *testing.T) {
+	s := licensetest.NewServer()
+	defer s.Close()
+	key := s.SeedValid("prod")
+	s.SetRateLimit(1, time.Second)
+
+	var attempts []api.Attempt
+	c := s.Client(
+		api.WithRetryPolicy(api.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}),
+		api.WithAttemptHook(func(a api.Attempt) { attempts = append(attempts, a) }),
+	)
+
+	if _, err := c.Valid(context.Background(), key, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	attempts = nil
+
+	start := time.Now()
+	valid, err := c.Valid(context.Background(), key, "prod")
+	if err != nil || !valid {
+		t.Fatalf("check after waiting out the limit: %v, %v", valid, err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("%d attempts, want 2", len(attempts))
+	}
+	if attempts[0].StatusCode != http.StatusTooManyRequests || attempts[0].Retry != time.Second {
+		t.Errorf("first attempt: status %d, retry in %v, want 429 and 1s", attempts[0].StatusCode, attempts[0].Retry)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, before Retry-After", elapsed)
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"github.com/go-resty/resty/v2"
+	"time"
+)
+
+// MaxClockSkew is how far a signed response's timestamp may be from the
+// local clock before it is rejected.
+var MaxClockSkew = 5 * time.Minute
+
+var (
+	ErrBadSignature  = errors.New("response signature invalid")
+	ErrNonceMismatch = errors.New("response does not answer the request")
+	ErrStale         = errors.New("response timestamp outside allowed skew")
+)
+
+// ParsePublicKey decodes a base64 Ed25519 key as served by /license/pubkey.
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+func GenerateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// VerifyCheckResponse makes sure resp was signed by the holder of pub and
+// answers req, rather than being replayed or forged.
+func VerifyCheckResponse(pub ed25519.PublicKey, req alp.CheckRequest, resp alp.LicenseResponse, now time.Time) error {
+	if err := VerifySignature(pub, resp); err != nil {
+		return err
+	}
+	if resp.Nonce != req.Nonce || resp.LicenseKey != req.Key || resp.Product != req.Product ||
+		resp.Version != req.Version || resp.ReleaseDate != req.ReleaseDate {
+		return ErrNonceMismatch
+	}
+	skew := now.Sub(time.Unix(resp.Timestamp, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrStale
+	}
+	return nil
+}
+
+// VerifySignature only makes sure resp was signed by the holder of pub. It
+// is for responses kept around after VerifyCheckResponse accepted them.
+func VerifySignature(pub ed25519.PublicKey, resp alp.LicenseResponse) error {
+	sig, err := base64.StdEncoding.DecodeString(resp.Signature)
+	if err != nil || !ed25519.Verify(pub, resp.SigningPayload(), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// CheckSignedValidity is CheckValidity for servers that sign their
+// responses. It only reports true for a fresh response signed by pub.
+//
+// Deprecated: use Client.Valid with WithPublicKey.
+func CheckSignedValidity(c *resty.Client, baseurl, key, product string, pub ed25519.PublicKey) bool {
+	return CheckSignedRequest(c, baseurl, alp.CheckRequest{Key: key, Product: product}, pub)
+}
+
+// CheckSignedRequest checks req against the server with a fresh nonce and
+// verifies the signed response.
+//
+// Deprecated: use Client.Check with WithPublicKey.
+func CheckSignedRequest(c *resty.Client, baseurl string, req alp.CheckRequest, pub ed25519.PublicKey) bool {
+	nonce, err := GenerateNonce()
+	if err != nil {
+		return false
+	}
+	req.Nonce = nonce
+
+	resp, err := c.R().
+		SetHeader("Accept", "application/json").
+		SetBody(req).
+		Post(baseurl + "/license/check")
+	if err != nil || resp.StatusCode()/100 != 2 {
+		return false
+	}
+
+	var respBody alp.LicenseResponse
+	if err = json.Unmarshal(resp.Body(), &respBody); err != nil {
+		return false
+	}
+	if VerifyCheckResponse(pub, req, respBody, time.Now()) != nil {
+		return false
+	}
+	return respBody.Status == alp.StatusValid
+}
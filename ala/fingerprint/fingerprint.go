@@ -0,0 +1,119 @@
+// Package fingerprint identifies a machine without revealing anything
+// about it.
+//
+// A fingerprint is built from up to three components: the systemd machine
+// id, the DMI product UUID and the MAC address of the primary network
+// interface. Each is hashed on its own with HMAC-SHA256 under a secret,
+// such as the license key or a random per-install value, so nobody without
+// it can recover a component by trying every MAC address or correlate the
+// same machine across vendors. The string form and matching are part of
+// the wire protocol and live in alp; a fingerprint is safe to store as-is.
+package fingerprint
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const Version = alp.FingerprintVersion
+
+var (
+	ErrNoComponents = errors.New("no machine identifiers available")
+	ErrInvalid      = alp.ErrInvalidFingerprint
+)
+
+// Components are the raw machine identifiers a fingerprint is built from.
+// They should not leave the machine.
+type Components struct {
+	MachineID   string
+	ProductUUID string
+	MAC         string
+}
+
+type Fingerprint = alp.Fingerprint
+
+// Collect reads the components of this machine. Anything it can't read,
+// such as the product UUID when not running as root, is left empty.
+func Collect() Components {
+	return Components{
+		MachineID:   readFirst("/etc/machine-id", "/var/lib/dbus/machine-id"),
+		ProductUUID: strings.ToLower(readFirst("/sys/class/dmi/id/product_uuid")),
+		MAC:         primaryMAC(),
+	}
+}
+
+// New hashes c under secret.
+func New(secret []byte, c Components) Fingerprint {
+	return Fingerprint{
+		MachineID:   hash(secret, "machine-id", c.MachineID),
+		ProductUUID: hash(secret, "product-uuid", c.ProductUUID),
+		MAC:         hash(secret, "mac", c.MAC),
+	}
+}
+
+func Parse(s string) (Fingerprint, error) {
+	return alp.ParseFingerprint(s)
+}
+
+// Generate fingerprints this machine under secret.
+func Generate(secret []byte) (Fingerprint, error) {
+	f := New(secret, Collect())
+	if f.MachineID == "" && f.ProductUUID == "" && f.MAC == "" {
+		return Fingerprint{}, ErrNoComponents
+	}
+	return f, nil
+}
+
+func hash(secret []byte, name, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(Version + "\x00" + name + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func readFirst(paths ...string) string {
+	for _, path := range paths {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			if s := strings.TrimSpace(string(b)); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// primaryMAC picks the first physical interface by name, so the choice
+// doesn't change with boot order. Virtual interfaces like docker0 or veth
+// pairs come and go and are only used when there is nothing else.
+func primaryMAC() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Name < ifaces[j].Name })
+
+	var fallback string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("/sys/class/net", iface.Name, "device")); err == nil {
+			return iface.HardwareAddr.String()
+		}
+		if fallback == "" {
+			fallback = iface.HardwareAddr.String()
+		}
+	}
+	return fallback
+}
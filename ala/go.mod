@@ -3,6 +3,11 @@ module github.com/GreatGodApollo/ala
 go 1.14
 
 require (
+	github.com/GreatGodApollo/alp v0.0.0
+	github.com/gin-gonic/gin v1.6.2
 	github.com/go-resty/resty/v2 v2.2.0
-	github.com/spf13/viper v1.6.2
+	github.com/kr/pretty v0.1.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 )
+
+replace github.com/GreatGodApollo/alp => ../alp
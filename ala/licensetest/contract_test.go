@@ -0,0 +1,153 @@
+package licensetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/alp/openapi"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// contract is a transport that checks every request the client sends and
+// every response it gets against the OpenAPI document.
+type contract struct {
+	t *testing.T
+
+	mu     sync.Mutex
+	routes map[string]int
+}
+
+func (c *contract) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+	}
+	withBody := func() *http.Request {
+		clone := r.Clone(r.Context())
+		if r.Body != nil {
+			clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		return clone
+	}
+
+	route, params, ok := openapi.Match(r.Method, r.URL.Path)
+	if !ok {
+		c.t.Errorf("%s %s is not in the OpenAPI document", r.Method, r.URL.Path)
+		return http.DefaultTransport.RoundTrip(withBody())
+	}
+	if err := openapi.ValidateRequest(withBody(), route, params); err != nil {
+		c.t.Errorf("request %s %s: %v", r.Method, r.URL, err)
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(withBody())
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	if err = openapi.ValidateResponse(r.Method, route, resp.StatusCode, respBody); err != nil {
+		c.t.Errorf("response to %s %s: %v: %s", r.Method, r.URL, err, respBody)
+	}
+
+	c.mu.Lock()
+	c.routes[r.Method+" "+route]++
+	c.mu.Unlock()
+	return resp, nil
+}
+
+func TestClientMatchesDocument(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetCredentials("admin", "secret")
+
+	checker := &contract{t: t, routes: map[string]int{}}
+	c := s.Client(api.WithHTTPClient(&http.Client{Transport: checker}))
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, alp.LicenseRequest{
+		Email:        "a@example.com",
+		Product:      "prod",
+		MaxVersion:   2,
+		UpdatesUntil: "2030-01-01",
+		Entitlements: []string{"export"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := created.LicenseKey
+	other := s.SeedValid("prod")
+
+	if _, err = c.Get(ctx, key); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.All(ctx, "prod"); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Licenses(ctx, "prod"); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Products(ctx); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Check(ctx, alp.CheckRequest{Key: key, Product: "prod", Version: "2.1.0", ReleaseDate: "2024-06-01"}); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Check(ctx, alp.CheckRequest{Key: key, Product: "other"}); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.PublicKey(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = c.DryRun().Suspend(ctx, other); err != nil {
+		t.Error(err)
+	}
+	for _, action := range []func(context.Context, string) (alp.LicenseResponse, error){c.Suspend, c.Suspend, c.Resume, c.Invalidate, c.Invalidate} {
+		if _, err = action(ctx, other); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err = c.History(ctx, other); err != nil {
+		t.Error(err)
+	}
+
+	apiKey, err := c.CreateAPIKey(ctx, alp.APIKeyRequest{Name: "ci", Scopes: []string{"licenses:read"}, Products: []string{"prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.APIKeys(ctx); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.RevokeAPIKey(ctx, apiKey.APIKey.Id); err != nil {
+		t.Error(err)
+	}
+
+	// Errors must be documented too.
+	if _, err = c.Get(ctx, "missing"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Get of a missing key: %v", err)
+	}
+	if _, err = c.Check(ctx, alp.CheckRequest{Key: key, Product: "prod", Version: "not-a-version"}); err == nil {
+		t.Error("Check with a bad version succeeded")
+	}
+	s.SetCredentials("admin", "other")
+	if _, err = c.Products(ctx); err == nil {
+		t.Error("Products with the wrong password succeeded")
+	}
+
+	if len(checker.routes) < 14 {
+		t.Errorf("only %d routes exercised: %v", len(checker.routes), checker.routes)
+	}
+}
@@ -0,0 +1,542 @@
+// Package licensetest provides an in-memory license server for tests of
+// code built on ala, so they don't need a running als and database.
+package licensetest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alp"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server speaks the license server protocol from memory. Everything about
+// it can be scripted while tests run.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	licenses   map[string]*alp.License
+	history    map[string][]alp.HistoryEntry
+	apiKeys    []alp.APIKey
+	nextId     int
+	username   string
+	password   string
+	apiKey     string
+	signKey    ed25519.PrivateKey
+	latency    time.Duration
+	errors     map[string]int
+	rateLimit  int
+	ratePer    time.Duration
+	rateStart  time.Time
+	checkCount int
+	calls      map[string]int
+}
+
+// NewServer starts a server. Admin routes accept any credentials until
+// SetCredentials or SetAPIKey is called.
+func NewServer() *Server {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic("licensetest: " + err.Error())
+	}
+
+	s := &Server{
+		licenses: map[string]*alp.License{},
+		history:  map[string][]alp.HistoryEntry{},
+		signKey:  priv,
+		errors:   map[string]int{},
+		calls:    map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a client for the server, with its credentials and signing
+// key configured. opts are applied after those.
+func (s *Server) Client(opts ...api.Option) *api.Client {
+	s.mu.Lock()
+	base := []api.Option{
+		api.WithBaseURL(s.URL),
+		api.WithPublicKey(s.PublicKey()),
+		api.WithBasicAuth(s.username, s.password),
+	}
+	if s.apiKey != "" {
+		base = append(base, api.WithAPIKey(s.apiKey))
+	}
+	s.mu.Unlock()
+	return api.NewClient(append(base, opts...)...)
+}
+
+func (s *Server) PublicKey() ed25519.PublicKey {
+	return s.signKey.Public().(ed25519.PublicKey)
+}
+
+// Seed adds a license and returns its key. A key is generated when lic has
+// none, and Valid should be set explicitly.
+func (s *Server) Seed(lic alp.License) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seed(lic)
+}
+
+// SeedValid adds a valid license for product and returns its key.
+func (s *Server) SeedValid(product string) string {
+	return s.Seed(alp.License{Product: product, Email: "test@example.com", Valid: true})
+}
+
+func (s *Server) seed(lic alp.License) string {
+	if lic.LicenseKey == "" {
+		lic.LicenseKey = randomKey()
+	}
+	s.nextId++
+	lic.Id = s.nextId
+	s.licenses[lic.LicenseKey] = &lic
+	s.record(lic.LicenseKey, "created")
+	return lic.LicenseKey
+}
+
+func (s *Server) record(key, action string) {
+	s.history[key] = append(s.history[key], alp.HistoryEntry{Action: action, Actor: "licensetest", CreatedAt: time.Now()})
+}
+
+func (s *Server) License(key string) (alp.License, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lic, ok := s.licenses[key]
+	if !ok {
+		return alp.License{}, false
+	}
+	return *lic, true
+}
+
+// SetValid flips a license's validity, as an invalidation would.
+func (s *Server) SetValid(key string, valid bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lic, ok := s.licenses[key]; ok {
+		lic.Valid = valid
+	}
+}
+
+func (s *Server) SetCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+func (s *Server) SetAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// ForceError makes every request to path answer with status until
+// ClearErrors is called. Path is matched as a prefix, so "/" fails
+// everything.
+func (s *Server) ForceError(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[path] = status
+}
+
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = map[string]int{}
+}
+
+// SetRateLimit allows n license checks every per, starting now, and
+// answers 429 with Retry-After beyond that. Zero removes the limit.
+func (s *Server) SetRateLimit(n int, per time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = n
+	s.ratePer = per
+	s.rateStart = time.Now()
+	s.checkCount = 0
+}
+
+// Calls returns how many requests were made to path.
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[path]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.calls[r.URL.Path]++
+	latency := s.latency
+	forced := 0
+	for path, status := range s.errors {
+		if strings.HasPrefix(r.URL.Path, path) {
+			forced = status
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if forced != 0 {
+		writeError(w, forced, errorCodes[forced], http.StatusText(forced))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/license/check":
+		s.check(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/license/pubkey":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"algorithm":  "ed25519",
+			"public_key": base64.StdEncoding.EncodeToString(s.PublicKey()),
+			"code":       http.StatusOK,
+		})
+	case strings.HasPrefix(r.URL.Path, "/api/v1/"):
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, alp.ErrorUnauthorized, "unauthorized")
+			return
+		}
+		s.admin(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/licenses":
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, alp.ErrorUnauthorized, "unauthorized")
+			return
+		}
+		s.list(w, r)
+	default:
+		writeError(w, http.StatusNotFound, alp.ErrorNotFound, "404: not found")
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.apiKey == "" && s.username == "" {
+		return true
+	}
+	if s.apiKey != "" && (r.Header.Get("Authorization") == "Bearer "+s.apiKey || r.Header.Get("X-API-Key") == s.apiKey) {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	return ok && s.username != "" && username == s.username && password == s.password
+}
+
+func (s *Server) check(w http.ResponseWriter, r *http.Request) {
+	var req alp.CheckRequest
+	if json.NewDecoder(r.Body).Decode(&req) != nil || req.Key == "" || req.Product == "" {
+		writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+		return
+	}
+
+	s.mu.Lock()
+	if s.rateLimit > 0 {
+		now := time.Now()
+		if now.Sub(s.rateStart) >= s.ratePer {
+			s.rateStart, s.checkCount = now, 0
+		}
+		s.checkCount++
+		if s.checkCount > s.rateLimit {
+			wait := s.rateStart.Add(s.ratePer).Sub(now)
+			s.mu.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, alp.ErrorRateLimited, "you have reached your limit!")
+			return
+		}
+	}
+	lic, ok := s.licenses[req.Key]
+	var licCopy alp.License
+	if ok {
+		licCopy = *lic
+	}
+	s.mu.Unlock()
+
+	resp := alp.LicenseResponse{LicenseKey: req.Key, Status: alp.StatusInvalid, Code: http.StatusOK}
+	status := http.StatusOK
+	switch {
+	case !ok:
+		status = http.StatusNotFound
+		resp.Message = alp.MessageLicenseNonexistent
+		resp.Code = http.StatusNotFound
+		resp.Error = alp.ErrorLicenseNotFound
+	case !licCopy.Valid:
+		resp.Message = "license invalid"
+	case licCopy.Product != req.Product:
+		resp.Message = alp.MessageIncorrectProduct
+		resp.Error = alp.ErrorProductMismatch
+	case licCopy.Suspended:
+		resp.Status = alp.StatusSuspended
+		resp.Message = "license suspended"
+	case licCopy.ExpiresAt != nil && licCopy.ExpiresAt.Before(time.Now()):
+		resp.Status = alp.StatusExpired
+		resp.Message = "license expired"
+	default:
+		covered, err := alp.VersionCovered(licCopy, req.Version, req.ReleaseDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, err.Error())
+			return
+		}
+		if !covered {
+			resp.Status = alp.StatusVersionNotCovered
+			resp.Message = "license does not cover this version"
+			break
+		}
+		resp.Status = alp.StatusValid
+		resp.Message = "license valid"
+		resp.ExpiresAt = licCopy.ExpiresAt
+		resp.Entitlements = licCopy.Entitlements
+	}
+
+	resp.Product = req.Product
+	resp.Version = req.Version
+	resp.ReleaseDate = req.ReleaseDate
+	resp.Nonce = req.Nonce
+	resp.Timestamp = time.Now().Unix()
+	resp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.signKey, resp.SigningPayload()))
+	writeJSON(w, status, resp)
+}
+
+// list serves GET /api/v2/licenses, the only v2 route it knows.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	product := r.URL.Query().Get("product")
+	if product == "" {
+		writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+		return
+	}
+
+	s.mu.Lock()
+	resp := alp.Licenses{Code: http.StatusOK, Licenses: []alp.License{}}
+	for _, lic := range s.licenses {
+		if lic.Product == product {
+			resp.Licenses = append(resp.Licenses, *lic)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(resp.Licenses, func(i, j int) bool { return resp.Licenses[i].Id < resp.Licenses[j].Id })
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) admin(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && path == "/create":
+		var req alp.LicenseRequest
+		if json.NewDecoder(r.Body).Decode(&req) != nil || req.Email == "" || req.Product == "" {
+			writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+			return
+		}
+		updatesUntil, err := alp.ParseDate(req.UpdatesUntil)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid date")
+			return
+		}
+		expiresAt, err := alp.ParseDate(req.ExpiresAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid date")
+			return
+		}
+		if dryRun {
+			writeJSON(w, http.StatusOK, alp.LicenseResponse{Status: alp.StatusDryRun, Message: "license would be created", Code: http.StatusOK})
+			return
+		}
+		key := s.seed(alp.License{
+			Product:      req.Product,
+			Email:        req.Email,
+			Valid:        true,
+			MaxVersion:   req.MaxVersion,
+			UpdatesUntil: updatesUntil,
+			ExpiresAt:    expiresAt,
+			Entitlements: req.Entitlements,
+		})
+		writeJSON(w, http.StatusCreated, alp.LicenseResponse{LicenseKey: key, Status: alp.StatusCreated, Message: "license created", Code: http.StatusCreated})
+	case r.Method == http.MethodPost && path == "/specific":
+		var req alp.BasicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		lic, ok := s.licenses[req.Key]
+		if !ok {
+			writeError(w, http.StatusNotFound, alp.ErrorLicenseNotFound, alp.MessageLicenseNonexistent)
+			return
+		}
+		resp := *lic
+		resp.Code = http.StatusOK
+		writeJSON(w, http.StatusOK, resp)
+	case r.Method == http.MethodPost && path == "/invalidate":
+		var req alp.BasicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := alp.LicenseResponse{LicenseKey: req.Key, Status: alp.StatusInvalid, Code: http.StatusOK}
+		if lic, ok := s.licenses[req.Key]; !ok {
+			writeError(w, http.StatusNotFound, alp.ErrorLicenseNotFound, alp.MessageLicenseNonexistent)
+			return
+		} else if !lic.Valid {
+			resp.Message = alp.MessageLicenseAlreadyInvalid
+		} else if dryRun {
+			resp.Status = alp.StatusDryRun
+			resp.Message = "license would be invalidated"
+		} else {
+			lic.Valid = false
+			s.record(req.Key, "invalidated")
+			resp.Status = alp.StatusInvalidated
+			resp.Message = "license invalidated"
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case r.Method == http.MethodPost && (path == "/suspend" || path == "/resume"):
+		var req alp.BasicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		suspend := path == "/suspend"
+		done := "resumed"
+		if suspend {
+			done = "suspended"
+		}
+		resp := alp.LicenseResponse{LicenseKey: req.Key, Status: alp.StatusInvalid, Code: http.StatusOK}
+		if lic, ok := s.licenses[req.Key]; !ok {
+			writeError(w, http.StatusNotFound, alp.ErrorLicenseNotFound, alp.MessageLicenseNonexistent)
+			return
+		} else if !lic.Valid {
+			resp.Message = alp.MessageLicenseAlreadyInvalid
+		} else if lic.Suspended == suspend {
+			resp.Status = alp.StatusUnchanged
+			resp.Message = "license already " + done
+		} else if dryRun {
+			resp.Status = alp.StatusDryRun
+			resp.Message = "license would be " + done
+		} else {
+			lic.Suspended = suspend
+			s.record(req.Key, done)
+			resp.Status = done
+			resp.Message = "license " + done
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case r.Method == http.MethodPost && path == "/history":
+		var req alp.BasicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if _, ok := s.licenses[req.Key]; !ok {
+			writeError(w, http.StatusNotFound, alp.ErrorLicenseNotFound, alp.MessageLicenseNonexistent)
+			return
+		}
+		writeJSON(w, http.StatusOK, alp.History{LicenseKey: req.Key, History: s.history[req.Key], Code: http.StatusOK})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/all/"):
+		product := strings.TrimPrefix(path, "/all/")
+		resp := alp.Licenses{Code: http.StatusOK}
+		for _, lic := range s.licenses {
+			if lic.Valid && lic.Product == product {
+				resp.Licenses = append(resp.Licenses, *lic)
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case r.Method == http.MethodGet && path == "/products":
+		seen := map[string]bool{}
+		resp := alp.Products{Code: http.StatusOK, Products: []string{}}
+		for _, lic := range s.licenses {
+			if !seen[lic.Product] {
+				seen[lic.Product] = true
+				resp.Products = append(resp.Products, lic.Product)
+			}
+		}
+		sort.Strings(resp.Products)
+		writeJSON(w, http.StatusOK, resp)
+	case r.Method == http.MethodPost && path == "/keys/create":
+		var req alp.APIKeyRequest
+		if json.NewDecoder(r.Body).Decode(&req) != nil || req.Name == "" || len(req.Scopes) == 0 {
+			writeError(w, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+			return
+		}
+		if dryRun {
+			writeJSON(w, http.StatusOK, alp.APIKeyResponse{
+				APIKey:  alp.APIKey{Name: req.Name, Scopes: req.Scopes, Products: req.Products},
+				Status:  alp.StatusDryRun,
+				Message: "api key would be created",
+				Code:    http.StatusOK,
+			})
+			return
+		}
+		key := "als_" + randomKey()
+		apiKey := alp.APIKey{
+			Id:        len(s.apiKeys) + 1,
+			Name:      req.Name,
+			Prefix:    key[:12],
+			Scopes:    req.Scopes,
+			Products:  req.Products,
+			CreatedAt: time.Now(),
+		}
+		s.apiKeys = append(s.apiKeys, apiKey)
+		writeJSON(w, http.StatusCreated, alp.APIKeyResponse{Key: key, APIKey: apiKey, Status: alp.StatusCreated, Message: "api key created", Code: http.StatusCreated})
+	case r.Method == http.MethodGet && path == "/keys/all":
+		writeJSON(w, http.StatusOK, alp.APIKeys{Code: http.StatusOK, Keys: s.apiKeys})
+	case r.Method == http.MethodPost && path == "/keys/revoke":
+		var req alp.RevokeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Id < 1 || req.Id > len(s.apiKeys) {
+			writeError(w, http.StatusNotFound, alp.ErrorNotFound, alp.MessageAPIKeyNonexistent)
+			return
+		}
+		if s.apiKeys[req.Id-1].Revoked {
+			writeError(w, http.StatusConflict, alp.ErrorConflict, alp.MessageAPIKeyAlreadyRevoked)
+			return
+		}
+		if dryRun {
+			writeJSON(w, http.StatusOK, alp.BasicResponse{Status: alp.StatusDryRun, Message: "api key would be revoked", Code: http.StatusOK})
+			return
+		}
+		s.apiKeys[req.Id-1].Revoked = true
+		writeJSON(w, http.StatusOK, alp.BasicResponse{Status: alp.StatusRevoked, Message: "api key revoked", Code: http.StatusOK})
+	default:
+		writeError(w, http.StatusNotFound, alp.ErrorNotFound, "404: not found")
+	}
+}
+
+func randomKey() string {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		panic("licensetest: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          alp.ErrorInvalidRequest,
+	http.StatusUnauthorized:        alp.ErrorUnauthorized,
+	http.StatusForbidden:           alp.ErrorForbidden,
+	http.StatusNotFound:            alp.ErrorNotFound,
+	http.StatusConflict:            alp.ErrorConflict,
+	http.StatusTooManyRequests:     alp.ErrorRateLimited,
+	http.StatusInternalServerError: alp.ErrorInternal,
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	if code == "" {
+		code = alp.ErrorInternal
+	}
+	writeJSON(w, status, alp.ErrorResponse{Status: alp.StatusError, Error: code, Message: message, Code: status})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
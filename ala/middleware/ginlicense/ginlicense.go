@@ -0,0 +1,34 @@
+// Package ginlicense adapts the license middleware to gin.
+package ginlicense
+
+import (
+	"github.com/GreatGodApollo/ala/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is where the license info is stored in the gin context.
+const ContextKey = "license"
+
+func Middleware(g *middleware.Gate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, denial := g.Evaluate(c.Request.Context())
+		if denial != nil {
+			g.Deny(c.Writer, c.Request, *denial)
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(middleware.NewContext(c.Request.Context(), info))
+		c.Set(ContextKey, info)
+		c.Next()
+	}
+}
+
+func FromContext(c *gin.Context) (middleware.Info, bool) {
+	info, ok := c.Get(ContextKey)
+	if !ok {
+		return middleware.Info{}, false
+	}
+	licInfo, ok := info.(middleware.Info)
+	return licInfo, ok
+}
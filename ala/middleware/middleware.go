@@ -0,0 +1,233 @@
+// Package middleware blocks HTTP requests when the license is missing,
+// invalid or lacks a required entitlement.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/GreatGodApollo/ala/validator"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Info describes the license a request was allowed under.
+type Info struct {
+	Key          string
+	Product      string
+	Status       string
+	Reason       validator.Reason
+	ExpiresAt    *time.Time
+	Entitlements []string
+}
+
+func (i Info) HasEntitlement(entitlement string) bool {
+	for _, e := range i.Entitlements {
+		if e == entitlement {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the license info the middleware stored in ctx.
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(contextKey{}).(Info)
+	return info, ok
+}
+
+const (
+	DenialInvalid            = "license_invalid"
+	DenialMissingEntitlement = "entitlement_missing"
+)
+
+// Denial explains why a request was refused.
+type Denial struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Message     string `json:"message"`
+	Entitlement string `json:"entitlement,omitempty"`
+}
+
+// Gate decides whether requests may proceed. It revalidates the license at
+// most once per TTL, in the background, so requests don't each reach the
+// license server or wait on it once there is an answer.
+type Gate struct {
+	shared   *shared
+	requires []string
+}
+
+type shared struct {
+	validator     *validator.Validator
+	ttl           time.Duration
+	timeout       time.Duration
+	invalidStatus int
+	missingStatus int
+	deny          func(http.ResponseWriter, *http.Request, Denial)
+
+	mu         sync.Mutex
+	result     validator.Result
+	checkedAt  time.Time
+	refreshing *refresh
+}
+
+// refresh is a revalidation in flight. result is set before done is closed.
+type refresh struct {
+	done   chan struct{}
+	result validator.Result
+}
+
+type Option func(*shared)
+
+func WithTTL(ttl time.Duration) Option {
+	return func(s *shared) {
+		s.ttl = ttl
+	}
+}
+
+// WithRefreshTimeout bounds each revalidation. It defaults to 30 seconds.
+func WithRefreshTimeout(timeout time.Duration) Option {
+	return func(s *shared) {
+		s.timeout = timeout
+	}
+}
+
+// WithStatusCodes sets the statuses used for an invalid license and for a
+// missing entitlement. They default to 402 and 403.
+func WithStatusCodes(invalid, missingEntitlement int) Option {
+	return func(s *shared) {
+		s.invalidStatus = invalid
+		s.missingStatus = missingEntitlement
+	}
+}
+
+// WithDenyHandler replaces the default JSON response for refused requests.
+func WithDenyHandler(deny func(http.ResponseWriter, *http.Request, Denial)) Option {
+	return func(s *shared) {
+		s.deny = deny
+	}
+}
+
+func New(v *validator.Validator, opts ...Option) *Gate {
+	s := &shared{
+		validator:     v,
+		ttl:           5 * time.Minute,
+		timeout:       30 * time.Second,
+		invalidStatus: http.StatusPaymentRequired,
+		missingStatus: http.StatusForbidden,
+		deny:          writeDenial,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return &Gate{shared: s}
+}
+
+// Require returns a gate that also requires the given entitlements. It
+// shares the license cache with g.
+func (g *Gate) Require(entitlements ...string) *Gate {
+	requires := append(append([]string(nil), g.requires...), entitlements...)
+	return &Gate{shared: g.shared, requires: requires}
+}
+
+// Evaluate returns the license info, or why the request must be refused.
+func (g *Gate) Evaluate(ctx context.Context) (Info, *Denial) {
+	result := g.shared.current(ctx)
+	info := Info{
+		Key:          g.shared.validator.Key(),
+		Product:      g.shared.validator.Product(),
+		Status:       result.Status,
+		Reason:       result.Reason,
+		ExpiresAt:    result.ExpiresAt,
+		Entitlements: result.Entitlements,
+	}
+
+	if !result.Valid {
+		return info, &Denial{
+			StatusCode: g.shared.invalidStatus,
+			Code:       DenialInvalid,
+			Message:    "a valid license is required",
+		}
+	}
+	for _, entitlement := range g.requires {
+		if !info.HasEntitlement(entitlement) {
+			return info, &Denial{
+				StatusCode:  g.shared.missingStatus,
+				Code:        DenialMissingEntitlement,
+				Message:     "the license does not include " + entitlement,
+				Entitlement: entitlement,
+			}
+		}
+	}
+	return info, nil
+}
+
+// Deny writes the refusal using the configured deny handler.
+func (g *Gate) Deny(w http.ResponseWriter, r *http.Request, denial Denial) {
+	g.shared.deny(w, r, denial)
+}
+
+func (g *Gate) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, denial := g.Evaluate(r.Context())
+		if denial != nil {
+			g.Deny(w, r, *denial)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), info)))
+	})
+}
+
+// current returns the latest result, starting a revalidation when it is
+// older than the TTL. Only the first requests wait for one to finish; after
+// that the previous result is served until the new one is in.
+func (s *shared) current(ctx context.Context) validator.Result {
+	s.mu.Lock()
+	if s.refreshing == nil && (s.checkedAt.IsZero() || time.Since(s.checkedAt) > s.ttl) {
+		s.refreshing = &refresh{done: make(chan struct{})}
+		go s.refresh(s.refreshing)
+	}
+	if !s.checkedAt.IsZero() {
+		result := s.result
+		s.mu.Unlock()
+		return result
+	}
+	r := s.refreshing
+	s.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.result
+	case <-ctx.Done():
+		return validator.Result{Err: ctx.Err()}
+	}
+}
+
+// refresh revalidates with its own deadline, so a request giving up doesn't
+// cut it short. A result that only says the deadline passed isn't kept.
+func (s *shared) refresh(r *refresh) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	r.result = s.validator.Validate(ctx)
+
+	s.mu.Lock()
+	if ctx.Err() == nil {
+		s.result = r.result
+		s.checkedAt = time.Now()
+	}
+	s.refreshing = nil
+	s.mu.Unlock()
+	close(r.done)
+}
+
+func writeDenial(w http.ResponseWriter, r *http.Request, denial Denial) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(denial.StatusCode)
+	json.NewEncoder(w).Encode(denial)
+}
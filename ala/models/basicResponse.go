@@ -1,7 +0,0 @@
-package models
-
-type BasicResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Code    int    `json:"code"`
-}
@@ -0,0 +1,161 @@
+package validator
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/ala/fingerprint"
+	"github.com/GreatGodApollo/alp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type cacheEntry struct {
+	Product      string     `json:"product"`
+	Valid        bool       `json:"valid"`
+	Status       string     `json:"status"`
+	CheckedAt    time.Time  `json:"checked_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Entitlements []string   `json:"entitlements,omitempty"`
+	Machine      string     `json:"machine"`
+	// Response is the server's signed answer, kept when the client pins
+	// the server's public key.
+	Response *alp.LicenseResponse `json:"response,omitempty"`
+	MAC      string               `json:"mac"`
+}
+
+var errCacheTampered = errors.New("license cache tampered with")
+
+func defaultCachePath(key, product string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(key + "\x00" + product))
+	return filepath.Join(dir, "ala", hex.EncodeToString(sum[:8])+".json")
+}
+
+// mac binds an entry to this license and the machine it was written on, so
+// a corrupted or carelessly edited file is rejected. Its key comes from the
+// license key, which anyone holding the file has too, so it does not stop
+// deliberate forgery. Only the signed response does that, when a public key
+// is pinned.
+func (v *Validator) mac(entry cacheEntry) string {
+	secret := sha256.Sum256([]byte(v.key))
+
+	var expiry int64
+	if entry.ExpiresAt != nil {
+		expiry = entry.ExpiresAt.Unix()
+	}
+
+	h := hmac.New(sha256.New, secret[:])
+	h.Write([]byte(entry.Product + "\n" +
+		strconv.FormatBool(entry.Valid) + "\n" +
+		entry.Status + "\n" +
+		strconv.FormatInt(entry.CheckedAt.UnixNano(), 10) + "\n" +
+		strconv.FormatInt(expiry, 10) + "\n" +
+		strings.Join(entry.Entitlements, ",") + "\n" +
+		entry.Machine))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sameMachine compares machine ids, tolerating one changed component when
+// both are fingerprints.
+func sameMachine(stored, current string) bool {
+	a, errA := fingerprint.Parse(stored)
+	b, errB := fingerprint.Parse(current)
+	if errA == nil && errB == nil {
+		return a.Match(b)
+	}
+	return stored == current
+}
+
+func (v *Validator) store(result Result, signed *alp.LicenseResponse) error {
+	entry := cacheEntry{
+		Product:      v.product,
+		Valid:        result.Valid,
+		Status:       result.Status,
+		CheckedAt:    result.CheckedAt,
+		ExpiresAt:    result.ExpiresAt,
+		Entitlements: result.Entitlements,
+		Response:     signed,
+	}
+	machine, err := v.machineID()
+	if err != nil {
+		return err
+	}
+	entry.Machine = machine
+	entry.MAC = v.mac(entry)
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(v.cachePath), 0700); err != nil {
+		return err
+	}
+	tmp := v.cachePath + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.cachePath)
+}
+
+func (v *Validator) load() (cacheEntry, error) {
+	b, err := ioutil.ReadFile(v.cachePath)
+	if err != nil {
+		return cacheEntry{}, err
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(b, &entry); err != nil {
+		return cacheEntry{}, errCacheTampered
+	}
+	if entry.Product != v.product || !hmac.Equal([]byte(v.mac(entry)), []byte(entry.MAC)) {
+		return cacheEntry{}, errCacheTampered
+	}
+
+	machine, err := v.machineID()
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	if !sameMachine(entry.Machine, machine) {
+		return cacheEntry{}, errCacheTampered
+	}
+	if pub := v.client.PinnedKey(); pub != nil {
+		return v.verified(pub, entry)
+	}
+	return entry, nil
+}
+
+// verified replaces what entry says about the license with its signed
+// response, after checking the signature and that it answers this
+// validator. Without one the entry only counts as invalid.
+func (v *Validator) verified(pub ed25519.PublicKey, entry cacheEntry) (cacheEntry, error) {
+	resp := entry.Response
+	if resp == nil {
+		entry.Valid = false
+		entry.ExpiresAt = nil
+		entry.Entitlements = nil
+		return entry, nil
+	}
+	if api.VerifySignature(pub, *resp) != nil ||
+		resp.LicenseKey != v.key || resp.Product != v.product || resp.Version != v.version {
+		return cacheEntry{}, errCacheTampered
+	}
+
+	entry.Valid = resp.Status == alp.StatusValid
+	entry.Status = resp.Status
+	entry.CheckedAt = time.Unix(resp.Timestamp, 0)
+	entry.ExpiresAt = resp.ExpiresAt
+	entry.Entitlements = resp.Entitlements
+	return entry, nil
+}
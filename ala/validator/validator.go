@@ -0,0 +1,206 @@
+// Package validator checks a license against the server and keeps working
+// through short outages by falling back to the last answer it got.
+package validator
+
+import (
+	"context"
+	"errors"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/ala/fingerprint"
+	"github.com/GreatGodApollo/alp"
+	"os"
+	"time"
+)
+
+type Reason string
+
+const (
+	// ReasonOnline means the server answered.
+	ReasonOnline Reason = "online"
+	// ReasonCached means the server could not be reached and the cached
+	// answer, still within the grace period, was used.
+	ReasonCached Reason = "cached"
+	// ReasonGraceExpired means the server could not be reached and the
+	// cached answer is older than the grace period.
+	ReasonGraceExpired Reason = "grace_expired"
+	// ReasonExpired means the server could not be reached and the cached
+	// license has expired since it answered.
+	ReasonExpired Reason = "expired"
+	// ReasonNoCache means the server could not be reached and there is no
+	// cached answer to fall back to.
+	ReasonNoCache Reason = "no_cache"
+	// ReasonCacheInvalid means the server could not be reached and the
+	// cache was modified, copied from another machine or is from the future.
+	ReasonCacheInvalid Reason = "cache_invalid"
+)
+
+type Result struct {
+	Valid bool
+	// Status is the server's status for the license, e.g. "valid" or
+	// "version_not_covered".
+	Status string
+	Reason Reason
+	// CheckedAt is when the server last answered.
+	CheckedAt time.Time
+	// ExpiresAt is when the license expires, if it does.
+	ExpiresAt *time.Time
+	// Entitlements are the features the license unlocks.
+	Entitlements []string
+	// Err is why the server could not be used, when Reason isn't online.
+	Err error
+}
+
+type Validator struct {
+	client      *api.Client
+	key         string
+	product     string
+	version     string
+	cachePath   string
+	gracePeriod time.Duration
+	machineID   func() (string, error)
+	now         func() time.Time
+}
+
+type Option func(*Validator)
+
+func WithCachePath(path string) Option {
+	return func(v *Validator) {
+		v.cachePath = path
+	}
+}
+
+func WithGracePeriod(grace time.Duration) Option {
+	return func(v *Validator) {
+		v.gracePeriod = grace
+	}
+}
+
+func WithVersion(version string) Option {
+	return func(v *Validator) {
+		v.version = version
+	}
+}
+
+// WithMachineID replaces how the machine the cache is bound to is
+// identified. By default it is a fingerprint, so a cache survives one of
+// the machine's identifiers changing.
+func WithMachineID(machineID func() (string, error)) Option {
+	return func(v *Validator) {
+		v.machineID = machineID
+	}
+}
+
+// New returns a validator for key and product with a three day grace
+// period, caching in the user's cache directory.
+func New(client *api.Client, key, product string, opts ...Option) *Validator {
+	v := &Validator{
+		client:      client,
+		key:         key,
+		product:     product,
+		gracePeriod: 72 * time.Hour,
+		machineID:   func() (string, error) { return machineID(key) },
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.cachePath == "" {
+		v.cachePath = defaultCachePath(key, product)
+	}
+	return v
+}
+
+func (v *Validator) Key() string {
+	return v.key
+}
+
+func (v *Validator) Product() string {
+	return v.product
+}
+
+func (v *Validator) GracePeriod() time.Duration {
+	return v.gracePeriod
+}
+
+// Validate asks the server about the license, caching definitive answers,
+// and falls back to the cache when the server can't be used.
+func (v *Validator) Validate(ctx context.Context) Result {
+	resp, err := v.client.Check(ctx, alp.CheckRequest{Key: v.key, Product: v.product, Version: v.version})
+	if err == nil || definitive(err) {
+		status := resp.Status
+		if err != nil {
+			status = "invalid"
+		}
+		result := Result{
+			Valid:        status == alp.StatusValid,
+			Status:       status,
+			Reason:       ReasonOnline,
+			CheckedAt:    v.now(),
+			ExpiresAt:    resp.ExpiresAt,
+			Entitlements: resp.Entitlements,
+		}
+		var signed *alp.LicenseResponse
+		if err == nil && v.client.PinnedKey() != nil {
+			signed = &resp
+		}
+		v.store(result, signed)
+		return result
+	}
+
+	return v.fallback(err)
+}
+
+func (v *Validator) fallback(cause error) Result {
+	entry, err := v.load()
+	if err != nil {
+		reason := ReasonCacheInvalid
+		if os.IsNotExist(err) {
+			reason = ReasonNoCache
+		}
+		return Result{Reason: reason, Err: cause}
+	}
+
+	result := Result{
+		Valid:        entry.Valid,
+		Status:       entry.Status,
+		Reason:       ReasonCached,
+		CheckedAt:    entry.CheckedAt,
+		ExpiresAt:    entry.ExpiresAt,
+		Entitlements: entry.Entitlements,
+		Err:          cause,
+	}
+	age := v.now().Sub(entry.CheckedAt)
+	if age < -api.MaxClockSkew {
+		return Result{Reason: ReasonCacheInvalid, Err: cause}
+	}
+	if age > v.gracePeriod {
+		result.Valid = false
+		result.Reason = ReasonGraceExpired
+	}
+	if result.Valid && entry.ExpiresAt != nil && entry.ExpiresAt.Before(v.now()) {
+		result.Valid = false
+		result.Status = alp.StatusExpired
+		result.Reason = ReasonExpired
+	}
+	return result
+}
+
+// definitive reports whether err is the server's final word on the license
+// rather than a reason it couldn't be asked.
+func definitive(err error) bool {
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode/100 == 4 && apiErr.StatusCode != 429
+}
+
+// machineID fingerprints the machine under the license key, so the cache
+// doesn't hold anything that identifies the machine to anyone else.
+func machineID(key string) (string, error) {
+	f, err := fingerprint.Generate([]byte(key))
+	if err != nil {
+		return os.Hostname()
+	}
+	return f.String(), nil
+}
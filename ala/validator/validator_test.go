@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"context"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/ala/licensetest"
+	"github.com/GreatGodApollo/alp"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpiredCacheIsInvalid(t *testing.T) {
+	s := licensetest.NewServer()
+	defer s.Close()
+	expires := time.Now().Add(time.Hour)
+	key := s.Seed(alp.License{Product: "prod", Valid: true, ExpiresAt: &expires})
+
+	dir, err := ioutil.TempDir("", "validator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := s.Client(api.WithRetryPolicy(api.RetryPolicy{MaxAttempts: 1}))
+	v := New(client, key, "prod", WithCachePath(filepath.Join(dir, "cache")))
+
+	if result := v.Validate(context.Background()); !result.Valid || result.Reason != ReasonOnline {
+		t.Fatalf("online check = %+v", result)
+	}
+
+	s.ForceError("/license/check", http.StatusServiceUnavailable)
+	v.now = func() time.Time { return expires.Add(-time.Minute) }
+	if result := v.Validate(context.Background()); !result.Valid || result.Reason != ReasonCached {
+		t.Errorf("cached check before expiry = %+v", result)
+	}
+
+	v.now = func() time.Time { return expires.Add(time.Minute) }
+	result := v.Validate(context.Background())
+	if result.Valid {
+		t.Error("expired cached license is valid")
+	}
+	if result.Reason != ReasonExpired || result.Status != alp.StatusExpired {
+		t.Errorf("expired cached license: reason %q, status %q", result.Reason, result.Status)
+	}
+}
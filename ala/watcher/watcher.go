@@ -0,0 +1,168 @@
+// Package watcher re-validates a license in the background so long running
+// services notice invalidations without restarting.
+package watcher
+
+import (
+	"context"
+	"github.com/GreatGodApollo/ala/validator"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateUnknown      State = "unknown"
+	StateValid        State = "valid"
+	StateExpiringSoon State = "expiring_soon"
+	StateInvalid      State = "invalid"
+)
+
+// Event describes the license's state after a check. Events are only sent
+// when the state changes.
+type Event struct {
+	Previous State
+	State    State
+	Result   validator.Result
+}
+
+type Watcher struct {
+	validator      *validator.Validator
+	interval       time.Duration
+	jitter         time.Duration
+	expiringWithin time.Duration
+	onChange       func(Event)
+	changes        chan Event
+
+	mu      sync.RWMutex
+	current Event
+}
+
+type Option func(*Watcher)
+
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithJitter spreads checks by up to jitter either side of the interval, so
+// a fleet of services doesn't hit the server at once.
+func WithJitter(jitter time.Duration) Option {
+	return func(w *Watcher) {
+		w.jitter = jitter
+	}
+}
+
+// WithExpiringWithin sets how close to expiry, or to the end of the
+// offline grace period, a license counts as expiring soon.
+func WithExpiringWithin(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.expiringWithin = d
+	}
+}
+
+// OnChange calls fn, from the watcher's goroutine, on every state change.
+func OnChange(fn func(Event)) Option {
+	return func(w *Watcher) {
+		w.onChange = fn
+	}
+}
+
+// New returns a watcher checking every hour, give or take five minutes.
+func New(v *validator.Validator, opts ...Option) *Watcher {
+	w := &Watcher{
+		validator:      v,
+		interval:       time.Hour,
+		jitter:         5 * time.Minute,
+		expiringWithin: 7 * 24 * time.Hour,
+		changes:        make(chan Event, 1),
+		current:        Event{Previous: StateUnknown, State: StateUnknown},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Changes returns a channel of state changes, closed when Run returns. Only
+// the latest change is kept for slow readers.
+func (w *Watcher) Changes() <-chan Event {
+	return w.changes
+}
+
+func (w *Watcher) Current() Event {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Run checks the license immediately and then on every interval until ctx
+// is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.changes)
+
+	for {
+		w.check(ctx)
+
+		timer := time.NewTimer(w.nextDelay())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Watcher) check(ctx context.Context) {
+	result := w.validator.Validate(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	state := w.state(result, time.Now())
+
+	w.mu.Lock()
+	previous := w.current.State
+	w.current = Event{Previous: previous, State: state, Result: result}
+	event := w.current
+	w.mu.Unlock()
+
+	if previous == state {
+		return
+	}
+	if w.onChange != nil {
+		w.onChange(event)
+	}
+	select {
+	case <-w.changes:
+	default:
+	}
+	w.changes <- event
+}
+
+func (w *Watcher) state(result validator.Result, now time.Time) State {
+	if !result.Valid || (result.ExpiresAt != nil && !result.ExpiresAt.After(now)) {
+		return StateInvalid
+	}
+	if result.ExpiresAt != nil && result.ExpiresAt.Sub(now) < w.expiringWithin {
+		return StateExpiringSoon
+	}
+	if result.Reason == validator.ReasonCached &&
+		result.CheckedAt.Add(w.validator.GracePeriod()).Sub(now) < w.expiringWithin {
+		return StateExpiringSoon
+	}
+	return StateValid
+}
+
+func (w *Watcher) nextDelay() time.Duration {
+	delay := w.interval
+	if w.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(2*w.jitter))) - w.jitter
+	}
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return delay
+}
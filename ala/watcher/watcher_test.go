@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"context"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/ala/licensetest"
+	"github.com/GreatGodApollo/ala/validator"
+	"github.com/GreatGodApollo/alp"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpiredCacheIsInvalid(t *testing.T) {
+	s := licensetest.NewServer()
+	defer s.Close()
+	expires := time.Now().Add(time.Second)
+	key := s.Seed(alp.License{Product: "prod", Valid: true, ExpiresAt: &expires})
+
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := s.Client(api.WithRetryPolicy(api.RetryPolicy{MaxAttempts: 1}))
+	v := validator.New(client, key, "prod", validator.WithCachePath(filepath.Join(dir, "cache")))
+	w := New(v, WithExpiringWithin(time.Hour))
+
+	w.check(context.Background())
+	if state := w.Current().State; state != StateExpiringSoon {
+		t.Fatalf("state before expiry = %s", state)
+	}
+
+	// The server goes away and the license expires while it is gone.
+	s.ForceError("/license/check", http.StatusServiceUnavailable)
+	time.Sleep(time.Until(expires) + 10*time.Millisecond)
+	w.check(context.Background())
+	event := w.Current()
+	if event.State != StateInvalid {
+		t.Errorf("state after expiry = %s, result %+v", event.State, event.Result)
+	}
+	if event.Result.Reason != validator.ReasonExpired {
+		t.Errorf("reason after expiry = %s", event.Result.Reason)
+	}
+}
+
+func TestStateExpired(t *testing.T) {
+	w := New(nil)
+	now := time.Now()
+	for _, expires := range []time.Time{now, now.Add(-time.Hour)} {
+		result := validator.Result{Valid: true, Reason: validator.ReasonOnline, CheckedAt: now, ExpiresAt: &expires}
+		if state := w.state(result, now); state != StateInvalid {
+			t.Errorf("license that expired %v ago is %s", now.Sub(expires), state)
+		}
+	}
+}
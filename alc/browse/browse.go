@@ -0,0 +1,425 @@
+// Package browse is a full-screen license browser for alc. It pages through
+// a product's licenses, filters them, shows their details and history and
+// invalidates or suspends them, all through the ala client.
+package browse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alp"
+	"github.com/gdamore/tcell"
+	"strings"
+)
+
+// Options tune the browser.
+type Options struct {
+	// OnChange is called after every invalidation, suspension or
+	// resumption made from the browser, so it can be audited.
+	OnChange func(action, key string, resp alp.LicenseResponse, err error)
+}
+
+type view int
+
+const (
+	viewProducts view = iota
+	viewLicenses
+	viewDetail
+)
+
+type browser struct {
+	ctx    context.Context
+	client *api.Client
+	opts   Options
+	screen tcell.Screen
+
+	view view
+
+	products []string
+	product  int
+
+	licenses []alp.License
+	// shown indexes the licenses that match the filter.
+	shown     []int
+	cursor    int
+	offset    int
+	filter    string
+	filtering bool
+
+	history    []alp.HistoryEntry
+	historyErr error
+
+	// question is asked on the status line; yes runs when it's answered.
+	question string
+	yes      func()
+	status   string
+	quit     bool
+}
+
+// Run shows the browser until the user quits. With a product it opens
+// straight onto that product's licenses, otherwise it starts by listing
+// products.
+func Run(ctx context.Context, c *api.Client, product string, opts Options) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err = screen.Init(); err != nil {
+		return err
+	}
+	defer screen.Fini()
+
+	return run(ctx, screen, c, product, opts)
+}
+
+func run(ctx context.Context, screen tcell.Screen, c *api.Client, product string, opts Options) error {
+	b := &browser{ctx: ctx, client: c, opts: opts, screen: screen}
+	screen.Clear()
+
+	if product != "" {
+		b.products = []string{product}
+		b.loadLicenses()
+	} else {
+		b.loadProducts()
+	}
+
+	for !b.quit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.draw()
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			b.handleKey(ev)
+		case nil:
+			return nil
+		}
+	}
+	return nil
+}
+
+// busy shows msg while a request is in flight.
+func (b *browser) busy(msg string) {
+	b.status = msg
+	b.draw()
+}
+
+func (b *browser) loadProducts() {
+	b.busy("Loading products...")
+	products, err := b.client.Products(b.ctx)
+	b.view = viewProducts
+	if err != nil {
+		b.status = "Could not load products: " + err.Error()
+		return
+	}
+	b.products = products
+	if b.product >= len(products) {
+		b.product = 0
+	}
+	b.status = ""
+	if len(products) == 0 {
+		b.status = "No products found!"
+	}
+}
+
+func (b *browser) loadLicenses() {
+	product := b.products[b.product]
+	b.busy("Loading " + product + "...")
+	licenses, err := b.client.Licenses(b.ctx, product)
+	if err != nil {
+		b.status = "Could not load licenses: " + err.Error()
+		return
+	}
+	b.view = viewLicenses
+	b.licenses = licenses
+	b.applyFilter()
+	b.status = ""
+}
+
+func (b *browser) loadHistory() {
+	b.busy("Loading history...")
+	b.history, b.historyErr = b.client.History(b.ctx, b.selected().LicenseKey)
+	b.status = ""
+}
+
+// applyFilter recomputes the shown licenses, keeping the cursor on the same
+// license when it's still shown.
+func (b *browser) applyFilter() {
+	current := -1
+	if b.cursor < len(b.shown) {
+		current = b.shown[b.cursor]
+	}
+
+	b.shown = b.shown[:0]
+	b.cursor = 0
+	for i, license := range b.licenses {
+		if !matches(license, b.filter) {
+			continue
+		}
+		if i == current {
+			b.cursor = len(b.shown)
+		}
+		b.shown = append(b.shown, i)
+	}
+}
+
+// matches reports whether license mentions every word of filter in its
+// key, email, state or entitlements.
+func matches(license alp.License, filter string) bool {
+	text := strings.ToLower(strings.Join([]string{
+		license.LicenseKey,
+		license.Email,
+		state(license),
+		strings.Join(license.Entitlements, " "),
+	}, " "))
+	for _, word := range strings.Fields(strings.ToLower(filter)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func state(license alp.License) string {
+	switch {
+	case !license.Valid:
+		return "invalid"
+	case license.Suspended:
+		return "suspended"
+	}
+	return "valid"
+}
+
+func (b *browser) selected() *alp.License {
+	if b.cursor >= len(b.shown) {
+		return nil
+	}
+	return &b.licenses[b.shown[b.cursor]]
+}
+
+// ask puts question on the status line and runs yes if it's answered
+// with y.
+func (b *browser) ask(question string, yes func()) {
+	b.question = question
+	b.yes = yes
+}
+
+func (b *browser) invalidate() {
+	license := b.selected()
+	if license == nil {
+		return
+	}
+	if !license.Valid {
+		b.status = "That license is already invalid."
+		return
+	}
+	b.ask("Invalidate "+license.Email+"'s license?", func() {
+		b.busy("Invalidating...")
+		resp, err := b.client.Invalidate(b.ctx, license.LicenseKey)
+		b.changed("invalidate", license.LicenseKey, resp, err)
+		if err == nil && resp.Status == alp.StatusInvalidated {
+			license.Valid = false
+		}
+	})
+}
+
+// toggleSuspended suspends the selected license, or resumes it when it's
+// already suspended.
+func (b *browser) toggleSuspended() {
+	license := b.selected()
+	if license == nil {
+		return
+	}
+	if !license.Valid {
+		b.status = "Invalid licenses can't be suspended."
+		return
+	}
+
+	action, call := "suspend", b.client.Suspend
+	if license.Suspended {
+		action, call = "resume", b.client.Resume
+	}
+	b.ask(strings.Title(action)+" "+license.Email+"'s license?", func() {
+		b.busy(strings.Title(action) + "ing...")
+		resp, err := call(b.ctx, license.LicenseKey)
+		b.changed(action, license.LicenseKey, resp, err)
+		if err == nil && (resp.Status == alp.StatusSuspended || resp.Status == alp.StatusResumed) {
+			license.Suspended = resp.Status == alp.StatusSuspended
+		}
+	})
+}
+
+// changed reports the outcome of a change and refreshes what depends on it.
+func (b *browser) changed(action, key string, resp alp.LicenseResponse, err error) {
+	if b.opts.OnChange != nil {
+		b.opts.OnChange(action, key, resp, err)
+	}
+
+	if err != nil {
+		b.status = "Could not " + action + ": " + err.Error()
+		if errors.Is(err, api.ErrNotFound) {
+			b.status = "That license doesn't exist anymore."
+		}
+		return
+	}
+	b.status = fmt.Sprintf("%s: %s", resp.Status, resp.Message)
+	if b.view == viewDetail {
+		b.loadHistory()
+		b.status = fmt.Sprintf("%s: %s", resp.Status, resp.Message)
+	}
+}
+
+func (b *browser) handleKey(ev *tcell.EventKey) {
+	if ev.Key() == tcell.KeyCtrlC {
+		b.quit = true
+		return
+	}
+	b.status = ""
+
+	if b.question != "" {
+		yes := b.yes
+		b.question, b.yes = "", nil
+		if r := typed(ev); r == 'y' || r == 'Y' {
+			yes()
+		} else {
+			b.status = "Nothing was changed."
+		}
+		return
+	}
+
+	if b.filtering {
+		b.editFilter(ev)
+		return
+	}
+
+	switch b.view {
+	case viewProducts:
+		b.productsKey(ev)
+	case viewLicenses:
+		b.licensesKey(ev)
+	case viewDetail:
+		b.detailKey(ev)
+	}
+}
+
+func (b *browser) productsKey(ev *tcell.EventKey) {
+	r := typed(ev)
+	switch {
+	case ev.Key() == tcell.KeyUp || r == 'k':
+		if b.product > 0 {
+			b.product--
+		}
+	case ev.Key() == tcell.KeyDown || r == 'j':
+		if b.product < len(b.products)-1 {
+			b.product++
+		}
+	case ev.Key() == tcell.KeyEnter:
+		if len(b.products) > 0 {
+			b.filter, b.cursor, b.offset = "", 0, 0
+			b.loadLicenses()
+		}
+	case r == 'r':
+		b.loadProducts()
+	case ev.Key() == tcell.KeyEscape || r == 'q':
+		b.quit = true
+	}
+}
+
+func (b *browser) licensesKey(ev *tcell.EventKey) {
+	r := typed(ev)
+	page := b.listHeight()
+	switch {
+	case ev.Key() == tcell.KeyUp || r == 'k':
+		b.move(-1)
+	case ev.Key() == tcell.KeyDown || r == 'j':
+		b.move(1)
+	case ev.Key() == tcell.KeyPgUp || r == 'b':
+		b.move(-page)
+	case ev.Key() == tcell.KeyPgDn || r == ' ':
+		b.move(page)
+	case ev.Key() == tcell.KeyHome || r == 'g':
+		b.move(-len(b.shown))
+	case ev.Key() == tcell.KeyEnd || r == 'G':
+		b.move(len(b.shown))
+	case ev.Key() == tcell.KeyEnter:
+		if b.selected() != nil {
+			b.view = viewDetail
+			b.loadHistory()
+		}
+	case r == '/':
+		b.filtering = true
+	case r == 'i':
+		b.invalidate()
+	case r == 's':
+		b.toggleSuspended()
+	case r == 'r':
+		b.loadLicenses()
+	case ev.Key() == tcell.KeyEscape && b.filter != "":
+		b.filter = ""
+		b.applyFilter()
+	case ev.Key() == tcell.KeyEscape || r == 'p':
+		b.loadProducts()
+	case r == 'q':
+		b.quit = true
+	}
+}
+
+func (b *browser) detailKey(ev *tcell.EventKey) {
+	r := typed(ev)
+	switch {
+	case ev.Key() == tcell.KeyEscape, ev.Key() == tcell.KeyBackspace, ev.Key() == tcell.KeyBackspace2, ev.Key() == tcell.KeyLeft:
+		b.view = viewLicenses
+	case ev.Key() == tcell.KeyUp || r == 'k':
+		b.move(-1)
+		b.loadHistory()
+	case ev.Key() == tcell.KeyDown || r == 'j':
+		b.move(1)
+		b.loadHistory()
+	case r == 'i':
+		b.invalidate()
+	case r == 's':
+		b.toggleSuspended()
+	case r == 'q':
+		b.quit = true
+	}
+}
+
+// typed returns the character typed, or 0 for special keys.
+func typed(ev *tcell.EventKey) rune {
+	if ev.Key() != tcell.KeyRune {
+		return 0
+	}
+	return ev.Rune()
+}
+
+func (b *browser) editFilter(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEnter:
+		b.filtering = false
+	case tcell.KeyEscape:
+		b.filtering = false
+		b.filter = ""
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if r := []rune(b.filter); len(r) > 0 {
+			b.filter = string(r[:len(r)-1])
+		}
+	case tcell.KeyCtrlU:
+		b.filter = ""
+	case tcell.KeyRune:
+		b.filter += string(ev.Rune())
+	}
+	b.applyFilter()
+}
+
+func (b *browser) move(n int) {
+	b.cursor += n
+	if b.cursor >= len(b.shown) {
+		b.cursor = len(b.shown) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
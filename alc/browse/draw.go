@@ -0,0 +1,296 @@
+package browse
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	styleNormal   = tcell.StyleDefault
+	styleBar      = tcell.StyleDefault.Reverse(true)
+	styleHeader   = tcell.StyleDefault.Bold(true)
+	styleCursor   = tcell.StyleDefault.Reverse(true)
+	styleHelp     = tcell.StyleDefault.Dim(true)
+	styleInvalid  = tcell.StyleDefault.Foreground(tcell.ColorRed)
+	styleSuspend  = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	styleQuestion = tcell.StyleDefault.Bold(true)
+)
+
+const (
+	expiresWidth = 10
+	stateWidth   = 9
+)
+
+func (b *browser) draw() {
+	b.screen.Clear()
+	switch b.view {
+	case viewProducts:
+		b.drawProducts()
+	case viewLicenses:
+		b.drawLicenses()
+	case viewDetail:
+		b.drawDetail()
+	}
+	b.drawStatus()
+	b.screen.Show()
+}
+
+// listHeight is how many licenses fit on the screen at once, below the
+// title and column headers and above the status and help lines.
+func (b *browser) listHeight() int {
+	_, h := b.screen.Size()
+	if h < 5 {
+		return 1
+	}
+	return h - 4
+}
+
+func (b *browser) drawBar(left, right string) {
+	w, _ := b.screen.Size()
+	fill(b.screen, 0, 0, w, styleBar)
+	put(b.screen, 1, 0, w-2, styleBar, left)
+	if right != "" {
+		put(b.screen, w-1-runewidth.StringWidth(right), 0, w, styleBar, right)
+	}
+}
+
+func (b *browser) drawProducts() {
+	b.drawBar("alc browse: products", fmt.Sprintf("%d products", len(b.products)))
+	w, h := b.screen.Size()
+	offset := scroll(b.product, 0, h-3)
+	for i := offset; i < len(b.products) && i-offset < h-3; i++ {
+		style := styleNormal
+		if i == b.product {
+			style = styleCursor
+			fill(b.screen, 0, 1+i-offset, w, style)
+		}
+		put(b.screen, 1, 1+i-offset, w-2, style, b.products[i])
+	}
+	b.drawHelp("↑↓ move  enter open  r reload  q quit")
+}
+
+func (b *browser) drawLicenses() {
+	w, _ := b.screen.Size()
+	height := b.listHeight()
+	b.offset = scroll(b.cursor, b.offset, height)
+
+	count := fmt.Sprintf("%d licenses", len(b.licenses))
+	if len(b.shown) != len(b.licenses) {
+		count = fmt.Sprintf("%d of %d licenses", len(b.shown), len(b.licenses))
+	}
+	if pages := (len(b.shown) + height - 1) / height; pages > 1 {
+		count += fmt.Sprintf("  page %d/%d", b.cursor/height+1, pages)
+	}
+	b.drawBar("alc browse: "+b.products[b.product], count)
+
+	keyWidth, emailWidth := b.columns(w)
+	x := 1
+	for _, col := range []struct {
+		name  string
+		width int
+	}{{"KEY", keyWidth}, {"EMAIL", emailWidth}, {"EXPIRES", expiresWidth}, {"STATE", stateWidth}} {
+		put(b.screen, x, 1, col.width, styleHeader, col.name)
+		x += col.width + 2
+	}
+
+	if len(b.shown) == 0 {
+		message := "No licenses found!"
+		if b.filter != "" {
+			message = "Nothing matches " + strconv.Quote(b.filter)
+		}
+		put(b.screen, 1, 2, w-2, styleHelp, message)
+	}
+	for row := 0; row < height && b.offset+row < len(b.shown); row++ {
+		i := b.offset + row
+		license := b.licenses[b.shown[i]]
+		y := 2 + row
+
+		style := styleNormal
+		switch state(license) {
+		case "invalid":
+			style = styleInvalid
+		case "suspended":
+			style = styleSuspend
+		}
+		if i == b.cursor {
+			style = styleCursor
+			fill(b.screen, 0, y, w, style)
+		}
+
+		x := 1
+		for _, cell := range []struct {
+			text  string
+			width int
+		}{
+			{license.LicenseKey, keyWidth},
+			{license.Email, emailWidth},
+			{formatDate(license.ExpiresAt), expiresWidth},
+			{state(license), stateWidth},
+		} {
+			put(b.screen, x, y, cell.width, style, cell.text)
+			x += cell.width + 2
+		}
+	}
+	b.drawHelp("↑↓ pgup pgdn move  / filter  enter details  i invalidate  s suspend/resume  p products  q quit")
+}
+
+// columns splits the width left over by the fixed columns between keys and
+// emails, giving keys no more than they need.
+func (b *browser) columns(w int) (keyWidth, emailWidth int) {
+	spare := w - 2 - expiresWidth - stateWidth - 3*2
+	for _, license := range b.licenses {
+		if n := runewidth.StringWidth(license.LicenseKey); n > keyWidth {
+			keyWidth = n
+		}
+	}
+	if keyWidth > spare/2 {
+		keyWidth = spare / 2
+	}
+	if keyWidth < 3 {
+		keyWidth = 3
+	}
+	emailWidth = spare - keyWidth
+	if emailWidth < 5 {
+		emailWidth = 5
+	}
+	return keyWidth, emailWidth
+}
+
+type field struct {
+	name, value string
+}
+
+func (b *browser) drawDetail() {
+	license := b.selected()
+	if license == nil {
+		b.view = viewLicenses
+		b.drawLicenses()
+		return
+	}
+	w, h := b.screen.Size()
+	b.drawBar("alc browse: "+license.Product, fmt.Sprintf("%d of %d", b.cursor+1, len(b.shown)))
+
+	lines := []field{
+		{"License Key", license.LicenseKey},
+		{"Product", license.Product},
+		{"Email", license.Email},
+		{"State", state(*license)},
+	}
+	if license.MaxVersion > 0 {
+		lines = append(lines, field{"Max Version", strconv.Itoa(license.MaxVersion)})
+	}
+	if license.UpdatesUntil != nil {
+		lines = append(lines, field{"Updates Until", formatDate(license.UpdatesUntil)})
+	}
+	if license.ExpiresAt != nil {
+		lines = append(lines, field{"Expires", formatDate(license.ExpiresAt)})
+	}
+	if len(license.Entitlements) > 0 {
+		lines = append(lines, field{"Entitlements", strings.Join(license.Entitlements, ", ")})
+	}
+
+	y := 2
+	for _, line := range lines {
+		put(b.screen, 1, y, 15, styleHeader, line.name+":")
+		put(b.screen, 17, y, w-18, styleNormal, line.value)
+		y++
+	}
+
+	y++
+	put(b.screen, 1, y, w-2, styleHeader, "History")
+	y++
+	switch {
+	case b.historyErr != nil:
+		put(b.screen, 1, y, w-2, styleInvalid, "Could not load history: "+b.historyErr.Error())
+	case len(b.history) == 0:
+		put(b.screen, 1, y, w-2, styleHelp, "Nothing recorded.")
+	}
+	// Show the latest entries when they don't all fit.
+	history := b.history
+	if room := h - 2 - y; room > 0 && len(history) > room {
+		history = history[len(history)-room:]
+	}
+	for _, entry := range history {
+		if y >= h-2 {
+			break
+		}
+		text := entry.CreatedAt.Local().Format("2006-01-02 15:04") + "  " + entry.Action + " by " + entry.Actor
+		if entry.Details != "" {
+			text += ": " + entry.Details
+		}
+		put(b.screen, 1, y, w-2, styleNormal, text)
+		y++
+	}
+	b.drawHelp("↑↓ next/previous  i invalidate  s suspend/resume  esc back  q quit")
+}
+
+// drawStatus fills the line above the help with the pending question, the
+// filter being typed or the last message.
+func (b *browser) drawStatus() {
+	w, h := b.screen.Size()
+	y := h - 2
+	switch {
+	case b.question != "":
+		put(b.screen, 1, y, w-2, styleQuestion, b.question+" [y/N]")
+	case b.filtering:
+		put(b.screen, 1, y, w-2, styleNormal, "/"+b.filter)
+		b.screen.ShowCursor(2+runewidth.StringWidth(b.filter), y)
+		return
+	case b.status != "":
+		put(b.screen, 1, y, w-2, styleNormal, b.status)
+	case b.filter != "" && b.view == viewLicenses:
+		put(b.screen, 1, y, w-2, styleHelp, "filter: "+b.filter+"  (esc clears)")
+	}
+	b.screen.HideCursor()
+}
+
+func (b *browser) drawHelp(help string) {
+	w, h := b.screen.Size()
+	put(b.screen, 1, h-1, w-2, styleHelp, help)
+}
+
+// scroll returns the first row to show so that cursor stays within height
+// rows, moving offset as little as possible.
+func scroll(cursor, offset, height int) int {
+	if height < 1 {
+		height = 1
+	}
+	if cursor < offset {
+		return cursor
+	}
+	if cursor >= offset+height {
+		return cursor - height + 1
+	}
+	return offset
+}
+
+// put writes text at x, y, cutting it off with … if it's wider than width.
+func put(s tcell.Screen, x, y, width int, style tcell.Style, text string) {
+	if width <= 0 {
+		return
+	}
+	if runewidth.StringWidth(text) > width {
+		text = runewidth.Truncate(text, width, "…")
+	}
+	for _, r := range text {
+		s.SetContent(x, y, r, nil, style)
+		x += runewidth.RuneWidth(r)
+	}
+}
+
+func fill(s tcell.Screen, x, y, width int, style tcell.Style) {
+	for i := 0; i < width; i++ {
+		s.SetContent(x+i, y, ' ', nil, style)
+	}
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"github.com/GreatGodApollo/alp"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// auditPath is where mutating commands are logged, one JSON object per
+// line. Empty disables the log.
+var auditPath string
+
+// SetAudit enables the audit log at path, or disables it when path is
+// empty.
+func SetAudit(path string) {
+	auditPath = path
+}
+
+type auditEntry struct {
+	Time     time.Time   `json:"time"`
+	Profile  string      `json:"profile"`
+	User     string      `json:"user"`
+	Host     string      `json:"host"`
+	Terminal string      `json:"terminal,omitempty"`
+	Command  []string    `json:"command"`
+	Code     int         `json:"code"`
+	Error    string      `json:"error,omitempty"`
+	Response interface{} `json:"response,omitempty"`
+}
+
+// lastResponse is the last value a command printed, so it can be audited.
+var lastResponse interface{}
+
+func audit(args []string, code int, err error) {
+	if auditPath == "" {
+		return
+	}
+
+	entry := auditEntry{
+		Time:     time.Now().UTC(),
+		Profile:  session.Profile,
+		Terminal: terminalName(),
+		Command:  args,
+		Code:     code,
+		Response: redact(lastResponse),
+	}
+	if u, uerr := user.Current(); uerr == nil {
+		entry.User = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	b, jerr := json.Marshal(entry)
+	if jerr != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(auditPath), 0700) != nil {
+		return
+	}
+	f, ferr := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if ferr != nil {
+		printError(ferr)
+		return
+	}
+	defer f.Close()
+	f.Write(append(b, '\n'))
+}
+
+// redact keeps secrets, like a new API key, out of the log.
+func redact(v interface{}) interface{} {
+	if resp, ok := v.(alp.APIKeyResponse); ok {
+		resp.Key = "[redacted]"
+		return resp
+	}
+	return v
+}
+
+func terminalName() string {
+	name, err := os.Readlink("/proc/self/fd/0")
+	if err != nil || filepath.Dir(name) != "/dev/pts" && filepath.Dir(name) != "/dev" {
+		return os.Getenv("SSH_TTY")
+	}
+	return name
+}
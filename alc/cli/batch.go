@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// batchInput is one row of a batch file. Value is the key or email the
+// command works on, Fields the row's other columns by header name.
+type batchInput struct {
+	Line   int
+	Value  string
+	Fields map[string]string
+}
+
+type batchRow struct {
+	Line    int    `json:"line"`
+	Input   string `json:"input"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Key     string `json:"key,omitempty"`
+}
+
+// Row statuses that count as failures.
+const (
+	batchError       = "error"
+	batchNonexistent = "nonexistent"
+)
+
+func batchFlags(fs *flag.FlagSet) {
+	fs.String("from", "", "Run for every key or email in a file, - for standard input")
+	fs.Int("concurrency", 4, "Requests to run at once with --from")
+	fs.String("results", "", "CSV file to write each row's status to with --from")
+}
+
+// readBatch reads one value per line, or a CSV file with a header row, in
+// which case the value is taken from the first of columns that exists.
+func readBatch(path string, columns ...string) ([]batchInput, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	br := bufio.NewReader(r)
+	first, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	firstLine := strings.SplitN(string(first), "\n", 2)[0]
+	if strings.HasSuffix(path, ".csv") || strings.Contains(firstLine, ",") {
+		return readBatchCSV(br, columns)
+	}
+
+	var inputs []batchInput
+	scanner := bufio.NewScanner(br)
+	line := 0
+	for scanner.Scan() {
+		line++
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" || strings.HasPrefix(value, "#") {
+			continue
+		}
+		inputs = append(inputs, batchInput{Line: line, Value: value})
+	}
+	return inputs, scanner.Err()
+}
+
+func readBatchCSV(r io.Reader, columns []string) ([]batchInput, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	column := -1
+	for _, want := range columns {
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), want) && column == -1 {
+				column = i
+			}
+		}
+	}
+	if column == -1 {
+		return nil, errors.New("no " + strings.Join(columns, " or ") + " column in the header")
+	}
+
+	var inputs []batchInput
+	for n, record := range records[1:] {
+		if column >= len(record) || strings.TrimSpace(record[column]) == "" {
+			continue
+		}
+		in := batchInput{Line: n + 2, Value: strings.TrimSpace(record[column]), Fields: map[string]string{}}
+		for i, name := range header {
+			if i < len(record) {
+				in.Fields[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(record[i])
+			}
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// runBatch calls fn for every input, at most concurrency at a time, and
+// returns the rows in input order. Progress goes to a terminal on stderr.
+func runBatch(ctx context.Context, inputs []batchInput, concurrency int, fn func(context.Context, batchInput) batchRow) []batchRow {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	rows := make([]batchRow, len(inputs))
+	progress := terminal.IsTerminal(int(os.Stderr.Fd())) && !quiet
+
+	var mu sync.Mutex
+	done, failed := 0, 0
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, in := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, in batchInput) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			row := fn(ctx, in)
+			row.Line, row.Input = in.Line, in.Value
+			rows[i] = row
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if row.Status == batchError || row.Status == batchNonexistent {
+				failed++
+			}
+			if progress {
+				fmt.Fprintf(os.Stderr, "\r[%d/%d] %d failed", done, len(inputs), failed)
+			}
+		}(i, in)
+	}
+	wg.Wait()
+	if progress {
+		fmt.Fprintln(os.Stderr)
+	}
+	return rows
+}
+
+// finishBatch prints the rows, writes the results file and picks the exit
+// code: errors beat licenses that don't exist or aren't valid.
+func finishBatch(fs *flag.FlagSet, rows []batchRow, invalid ...string) error {
+	if path := stringFlag(fs, "results"); path != "" {
+		if err := writeBatchResults(path, rows); err != nil {
+			return err
+		}
+	}
+
+	r := result{
+		value:  rows,
+		header: []string{"line", "input", "status", "message", "key"},
+		text: func() {
+			for _, row := range rows {
+				fmt.Printf("%d\t%s\t%s\t%s\n", row.Line, row.Input, row.Status, row.Message)
+			}
+		},
+	}
+	errored, notValid := 0, 0
+	for _, row := range rows {
+		r.rows = append(r.rows, []string{strconv.Itoa(row.Line), row.Input, row.Status, row.Message, row.Key})
+		if row.Key != "" {
+			r.keys = append(r.keys, row.Key)
+		}
+		switch {
+		case row.Status == batchError:
+			errored++
+		case row.Status == batchNonexistent || contains(invalid, row.Status):
+			notValid++
+		}
+	}
+	if err := emit(r); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d rows, %d errors, %d not valid\n", len(rows), errored, notValid)
+	if errored > 0 {
+		return errors.New("some rows failed")
+	}
+	if notValid > 0 {
+		return errInvalid
+	}
+	return nil
+}
+
+func writeBatchResults(path string, rows []batchRow) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"line", "input", "status", "message", "key"})
+	for _, row := range rows {
+		w.Write([]string{strconv.Itoa(row.Line), row.Input, row.Status, row.Message, row.Key})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// errorRow turns err into a row, telling missing licenses apart.
+func errorRow(err error) batchRow {
+	if errors.Is(err, api.ErrNotFound) {
+		return batchRow{Status: batchNonexistent, Message: err.Error()}
+	}
+	return batchRow{Status: batchError, Message: err.Error()}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+// Package cli runs alc commands, either one at a time from the command line
+// and the prompt or in bulk from a script.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Exit codes, shared by single commands and scripts.
+const (
+	ExitOK      = 0
+	ExitError   = 1
+	ExitUsage   = 2
+	ExitInvalid = 3
+)
+
+var (
+	// errUsage makes Execute print the command's usage.
+	errUsage = errors.New("usage")
+	// errInvalid means the command worked but the license isn't valid or
+	// doesn't exist. The command has already said so.
+	errInvalid = errors.New("license not valid")
+	errQuit    = errors.New("quit")
+	errHelp    = errors.New("help shown")
+)
+
+var client *api.Client
+
+func SetClient(c *api.Client) {
+	client = c
+}
+
+// Execute runs one command and returns its exit code. quit is true when the
+// command asked to leave alc.
+func Execute(ctx context.Context, args []string) (code int, quit bool) {
+	if len(args) == 0 {
+		return ExitOK, false
+	}
+
+	cmd, ok := lookup(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q, see help\n", args[0])
+		return ExitUsage, false
+	}
+
+	fs := cmd.FlagSet()
+	lastResponse = nil
+	positional, err := parseArgs(fs, args[1:])
+	if err == nil {
+		err = cmd.run(ctx, fs, positional)
+	}
+
+	code = ExitOK
+	switch {
+	case err == nil, errors.Is(err, errHelp):
+	case errors.Is(err, errQuit):
+		quit = true
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, "usage:", cmd.Usage)
+		code = ExitUsage
+	case errors.Is(err, errInvalid):
+		code = ExitInvalid
+	default:
+		printError(err)
+		code = ExitError
+	}
+
+	if cmd.mutating && !errors.Is(err, errHelp) && !errors.Is(err, errUsage) {
+		audit(args, code, err)
+	}
+	return code, quit
+}
+
+// RunScript runs one command per line of r, skipping blank lines and lines
+// starting with #. It stops at the first command that fails and returns
+// its exit code.
+func RunScript(ctx context.Context, r io.Reader) int {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		args, err := Split(text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Line %d: %s\n", line, err)
+			return ExitUsage
+		}
+		code, quit := Execute(ctx, args)
+		if code != ExitOK {
+			fmt.Fprintf(os.Stderr, "Stopped at line %d: %s\n", line, text)
+			return code
+		}
+		if quit {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		printError(err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// RunScriptFile runs the script at path, or standard input when path is -.
+func RunScriptFile(ctx context.Context, path string) int {
+	if path == "-" {
+		return RunScript(ctx, os.Stdin)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		printError(err)
+		return ExitError
+	}
+	return RunScript(ctx, strings.NewReader(string(b)))
+}
+
+// parseArgs parses flags wherever they appear among args and returns the
+// remaining positional arguments. Everything after -- is positional.
+// --help shows the command's help.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err == flag.ErrHelp {
+			if cmd, ok := lookup(fs.Name()); ok {
+				printHelp(cmd)
+			}
+			return nil, errHelp
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, errUsage
+		}
+
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
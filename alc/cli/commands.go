@@ -0,0 +1,612 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alc/browse"
+	"github.com/GreatGodApollo/alp"
+	"golang.org/x/crypto/ssh/terminal"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	// Args names the positional arguments in order, so completion knows
+	// to offer license keys for "key" and products for "product".
+	Args []string
+	// local commands don't talk to the server.
+	local bool
+	// mutating commands change the server's state and are audited.
+	mutating bool
+	flags    func(fs *flag.FlagSet)
+	run      func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+// FlagSet returns a fresh set of the command's flags.
+func (cmd Command) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	return fs
+}
+
+var commands []Command
+
+func init() {
+	commands = []Command{
+		// Basics
+		{Name: "exit", Usage: "exit", Description: "Quit ALC", local: true, run: runExit},
+		{Name: "help", Usage: "help [command]", Description: "List commands, or show how to use one", Args: []string{"command"}, local: true, run: runHelp},
+
+		// Profiles
+		{Name: "profiles", Usage: "profiles", Description: "List connection profiles", local: true, run: runProfiles},
+		{Name: "login", Usage: "login [--api-key]", Description: "Store encrypted credentials for the profile", local: true, flags: loginFlags, run: runLogin},
+		{Name: "logout", Usage: "logout", Description: "Forget the profile's stored credentials", local: true, run: runLogout},
+
+		// API Stuff
+		{Name: "all", Usage: "all <product> [flags]", Description: "Get valid licenses for a product", Args: []string{"product"}, flags: allFlags, run: runAll},
+		{Name: "new", Usage: "new <email> <product> [max-version] [updates-until] [flags] | new --from <file> [product] [flags]", Description: "Generate a new license for a product", Args: []string{"email", "product", "max-version", "updates-until"}, flags: newFlags, mutating: true, run: runNew},
+		{Name: "invalidate", Usage: "invalidate <key> [--yes] [--dry-run] | invalidate --from <file> [flags]", Description: "Invalidate a license", Args: []string{"key"}, mutating: true, flags: invalidateFlags, run: runInvalidate},
+		{Name: "suspend", Usage: "suspend <key> [--yes] [--dry-run]", Description: "Suspend a license until it is resumed", Args: []string{"key"}, mutating: true, flags: confirmFlags, run: runSuspend},
+		{Name: "resume", Usage: "resume <key> [--yes] [--dry-run]", Description: "Resume a suspended license", Args: []string{"key"}, mutating: true, flags: confirmFlags, run: runResume},
+		{Name: "get", Usage: "get <key> | get --from <file> [flags]", Description: "Get a specific license", Args: []string{"key"}, flags: batchFlags, run: runGet},
+		{Name: "check", Usage: "check <key> <product> [version] [flags] | check --from <file> [product] [flags]", Description: "Check if a license is valid", Args: []string{"key", "product", "version"}, flags: checkFlags, run: runCheck},
+		{Name: "history", Usage: "history <key>", Description: "Show what has been done to a license", Args: []string{"key"}, run: runHistory},
+		{Name: "products", Usage: "products", Description: "List products with licenses", run: runProducts},
+		{Name: "browse", Usage: "browse [product]", Description: "Browse licenses full-screen", Args: []string{"product"}, run: runBrowse},
+
+		// API Keys
+		{Name: "keys", Usage: "keys", Description: "List API keys", run: runKeys},
+		{Name: "key-new", Usage: "key-new <name> <scope,...> [product,...|*] [expires]", Description: "Mint a new API key", Args: []string{"name", "scopes", "products", "expires"}, flags: keyNewFlags, mutating: true, run: runKeyNew},
+		{Name: "key-revoke", Usage: "key-revoke <id> [--yes] [--dry-run]", Description: "Revoke an API key", Args: []string{"id"}, mutating: true, flags: confirmFlags, run: runKeyRevoke},
+	}
+}
+
+// Commands lists the commands in the order help shows them.
+func Commands() []Command {
+	return commands
+}
+
+// IsLocal reports whether the command named name works without the server.
+func IsLocal(name string) bool {
+	cmd, ok := lookup(name)
+	return ok && cmd.local
+}
+
+func lookup(name string) (Command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+func stringFlag(fs *flag.FlagSet, name string) string {
+	return fs.Lookup(name).Value.String()
+}
+
+func intFlag(fs *flag.FlagSet, name string) int {
+	n, _ := strconv.Atoi(stringFlag(fs, name))
+	return n
+}
+
+func boolFlag(fs *flag.FlagSet, name string) bool {
+	b, _ := strconv.ParseBool(stringFlag(fs, name))
+	return b
+}
+
+func printHelp(cmd Command) {
+	fmt.Println("usage:", cmd.Usage)
+	fmt.Println(cmd.Description)
+
+	fs := cmd.FlagSet()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	first := true
+	fs.VisitAll(func(f *flag.Flag) {
+		if first {
+			fmt.Fprintln(w, "\nflags:")
+			first = false
+		}
+		fmt.Fprintf(w, "  --%s\t%s\n", f.Name, f.Usage)
+	})
+	w.Flush()
+}
+
+func runExit(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	return errQuit
+}
+
+func runHelp(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	if len(args) == 1 {
+		cmd, ok := lookup(args[0])
+		if !ok {
+			return errors.New("unknown command " + args[0])
+		}
+		printHelp(cmd)
+		return nil
+	}
+
+	for _, cmd := range commands {
+		fmt.Printf("%s - %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Println("\nUse help <command> or <command> --help for details.")
+	return nil
+}
+
+func newFlags(fs *flag.FlagSet) {
+	fs.String("email", "", "Email of the licensee")
+	fs.String("product", "", "Product the license is for")
+	fs.Int("max-version", 0, "Highest major version covered, 0 for all")
+	fs.String("updates-until", "", "Last release date covered, as YYYY-MM-DD")
+	fs.String("expires", "", "Date the license expires, as YYYY-MM-DD")
+	fs.String("entitlements", "", "Comma separated features the license unlocks")
+	dryRunFlags(fs)
+	batchFlags(fs)
+}
+
+func runNew(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if from := stringFlag(fs, "from"); from != "" {
+		return runNewBatch(ctx, fs, from, args)
+	}
+
+	// Positional arguments are kept for the prompt's older syntax.
+	if len(args) > 4 {
+		return errUsage
+	}
+	req := alp.LicenseRequest{
+		Email:        stringFlag(fs, "email"),
+		Product:      stringFlag(fs, "product"),
+		MaxVersion:   intFlag(fs, "max-version"),
+		UpdatesUntil: stringFlag(fs, "updates-until"),
+		ExpiresAt:    stringFlag(fs, "expires"),
+	}
+	if len(args) > 0 {
+		req.Email = args[0]
+	}
+	if len(args) > 1 {
+		req.Product = args[1]
+	}
+	if len(args) > 2 {
+		maxVersion, err := strconv.Atoi(args[2])
+		if err != nil {
+			return errUsage
+		}
+		req.MaxVersion = maxVersion
+	}
+	if len(args) > 3 {
+		req.UpdatesUntil = args[3]
+	}
+	if req.Email == "" || req.Product == "" {
+		return errUsage
+	}
+	if entitlements := stringFlag(fs, "entitlements"); entitlements != "" {
+		req.Entitlements = strings.Split(entitlements, ",")
+	}
+
+	resp, err := target(fs).Create(ctx, req)
+	if err != nil {
+		return err
+	}
+	rememberProduct(req.Product)
+	rememberKey(resp.LicenseKey)
+	return emit(licenseResponseResult(resp))
+}
+
+// runNewBatch creates a license for every email in from, for the product
+// in the row's product column or else the one given.
+func runNewBatch(ctx context.Context, fs *flag.FlagSet, from string, args []string) error {
+	product := stringFlag(fs, "product")
+	if len(args) == 1 {
+		product = args[0]
+	} else if len(args) > 1 {
+		return errUsage
+	}
+	inputs, err := readBatch(from, "email")
+	if err != nil {
+		return err
+	}
+
+	c := target(fs)
+	rows := runBatch(ctx, inputs, intFlag(fs, "concurrency"), func(ctx context.Context, in batchInput) batchRow {
+		req := alp.LicenseRequest{
+			Email:        in.Value,
+			Product:      product,
+			MaxVersion:   intFlag(fs, "max-version"),
+			UpdatesUntil: stringFlag(fs, "updates-until"),
+			ExpiresAt:    stringFlag(fs, "expires"),
+		}
+		if p := in.Fields["product"]; p != "" {
+			req.Product = p
+		}
+		if entitlements := stringFlag(fs, "entitlements"); entitlements != "" {
+			req.Entitlements = strings.Split(entitlements, ",")
+		}
+		if req.Product == "" {
+			return batchRow{Status: batchError, Message: "no product"}
+		}
+		resp, err := c.Create(ctx, req)
+		if err != nil {
+			return errorRow(err)
+		}
+		return batchRow{Status: resp.Status, Message: resp.Message, Key: resp.LicenseKey}
+	})
+	return finishBatch(fs, rows)
+}
+
+func allFlags(fs *flag.FlagSet) {
+	fs.String("product", "", "Product to list licenses for")
+}
+
+func runAll(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	product := stringFlag(fs, "product")
+	if len(args) == 1 {
+		product = args[0]
+	}
+	if product == "" || len(args) > 1 {
+		return errUsage
+	}
+
+	licenses, err := client.All(ctx, product)
+	if err != nil {
+		return err
+	}
+	rememberProduct(product)
+	return emit(licensesResult(licenses, "No valid licenses found for that product!"))
+}
+
+func runGet(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if from := stringFlag(fs, "from"); from != "" {
+		if len(args) != 0 {
+			return errUsage
+		}
+		inputs, err := readBatch(from, "key", "license_key")
+		if err != nil {
+			return err
+		}
+		rows := runBatch(ctx, inputs, intFlag(fs, "concurrency"), func(ctx context.Context, in batchInput) batchRow {
+			license, err := client.Get(ctx, in.Value)
+			if err != nil {
+				return errorRow(err)
+			}
+			row := batchRow{Status: alp.StatusValid, Message: license.Product + " " + license.Email}
+			if !license.Valid {
+				row.Status = "invalid"
+			}
+			return row
+		})
+		return finishBatch(fs, rows, "invalid")
+	}
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	license, err := client.Get(ctx, args[0])
+	if errors.Is(err, api.ErrNotFound) {
+		fmt.Fprintln(os.Stderr, "That license doesn't exist!")
+		return errInvalid
+	} else if err != nil {
+		return err
+	}
+	rememberKey(args[0])
+	return emit(licenseResult(license))
+}
+
+func checkFlags(fs *flag.FlagSet) {
+	fs.String("version", "", "Version of the product being checked")
+	fs.String("release-date", "", "Release date of that version, as YYYY-MM-DD")
+	batchFlags(fs)
+}
+
+func runCheck(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if from := stringFlag(fs, "from"); from != "" {
+		if len(args) > 1 {
+			return errUsage
+		}
+		inputs, err := readBatch(from, "key", "license_key")
+		if err != nil {
+			return err
+		}
+		rows := runBatch(ctx, inputs, intFlag(fs, "concurrency"), func(ctx context.Context, in batchInput) batchRow {
+			req := alp.CheckRequest{Key: in.Value, Version: stringFlag(fs, "version"), ReleaseDate: stringFlag(fs, "release-date")}
+			if len(args) == 1 {
+				req.Product = args[0]
+			}
+			if p := in.Fields["product"]; p != "" {
+				req.Product = p
+			}
+			if req.Product == "" {
+				return batchRow{Status: batchError, Message: "no product"}
+			}
+			resp, err := client.Check(ctx, req)
+			if err != nil {
+				return errorRow(err)
+			}
+			return batchRow{Status: resp.Status, Message: resp.Message}
+		})
+		return finishBatch(fs, rows, "invalid", "expired", "version_not_covered")
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		return errUsage
+	}
+
+	req := alp.CheckRequest{Key: args[0], Product: args[1], Version: stringFlag(fs, "version"), ReleaseDate: stringFlag(fs, "release-date")}
+	if len(args) == 3 {
+		req.Version = args[2]
+	}
+	resp, err := client.Check(ctx, req)
+	if err != nil && !errors.Is(err, api.ErrNotFound) {
+		return err
+	}
+
+	status := resp.Status
+	if status == "" {
+		status = "nonexistent"
+	} else {
+		rememberKey(req.Key)
+		rememberProduct(req.Product)
+	}
+	check := checkOutput{LicenseKey: req.Key, Product: req.Product, Version: req.Version, Status: status, Valid: status == alp.StatusValid}
+	if err = emit(checkResult(check)); err != nil {
+		return err
+	}
+	if !check.Valid {
+		return errInvalid
+	}
+	return nil
+}
+
+func invalidateFlags(fs *flag.FlagSet) {
+	confirmFlags(fs)
+	batchFlags(fs)
+}
+
+func runInvalidate(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if from := stringFlag(fs, "from"); from != "" {
+		if len(args) != 0 {
+			return errUsage
+		}
+		inputs, err := readBatch(from, "key", "license_key")
+		if err != nil {
+			return err
+		}
+		if !boolFlag(fs, "dry-run") {
+			if err = confirm(fs, fmt.Sprintf("Invalidate %d licenses?", len(inputs))); err != nil {
+				return err
+			}
+		}
+
+		c := target(fs)
+		rows := runBatch(ctx, inputs, intFlag(fs, "concurrency"), func(ctx context.Context, in batchInput) batchRow {
+			resp, err := c.Invalidate(ctx, in.Value)
+			if err != nil {
+				return errorRow(err)
+			}
+			return batchRow{Status: resp.Status, Message: resp.Message}
+		})
+		return finishBatch(fs, rows)
+	}
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	// Show what is about to be invalidated, in case it's the wrong key.
+	license, err := client.Get(ctx, args[0])
+	if errors.Is(err, api.ErrNotFound) {
+		fmt.Fprintln(os.Stderr, "That license doesn't exist!")
+		return errInvalid
+	} else if err != nil {
+		return err
+	}
+	printLicense(os.Stderr, license)
+	if !boolFlag(fs, "dry-run") {
+		if err = confirm(fs, "Invalidate this license?"); err != nil {
+			return err
+		}
+	}
+
+	resp, err := target(fs).Invalidate(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	rememberKey(args[0])
+	return emit(licenseResponseResult(resp))
+}
+
+func runSuspend(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	return suspend(ctx, fs, args, true)
+}
+
+func runResume(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	return suspend(ctx, fs, args, false)
+}
+
+func suspend(ctx context.Context, fs *flag.FlagSet, args []string, suspended bool) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	license, err := client.Get(ctx, args[0])
+	if errors.Is(err, api.ErrNotFound) {
+		fmt.Fprintln(os.Stderr, "That license doesn't exist!")
+		return errInvalid
+	} else if err != nil {
+		return err
+	}
+	printLicense(os.Stderr, license)
+
+	action, call := "Suspend", target(fs).Suspend
+	if !suspended {
+		action, call = "Resume", target(fs).Resume
+	}
+	if !boolFlag(fs, "dry-run") {
+		if err = confirm(fs, action+" this license?"); err != nil {
+			return err
+		}
+	}
+
+	resp, err := call(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	rememberKey(args[0])
+	return emit(licenseResponseResult(resp))
+}
+
+func runHistory(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	history, err := client.History(ctx, args[0])
+	if errors.Is(err, api.ErrNotFound) {
+		fmt.Fprintln(os.Stderr, "That license doesn't exist!")
+		return errInvalid
+	} else if err != nil {
+		return err
+	}
+	rememberKey(args[0])
+	return emit(historyResult(history))
+}
+
+func runBrowse(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("browse needs a terminal")
+	}
+
+	product := ""
+	if len(args) == 1 {
+		product = args[0]
+	}
+	return browse.Run(ctx, client, product, browse.Options{
+		OnChange: func(action, key string, resp alp.LicenseResponse, err error) {
+			lastResponse = resp
+			code := ExitOK
+			if err != nil {
+				code = ExitError
+			}
+			audit([]string{"browse", action, key}, code, err)
+		},
+	})
+}
+
+func runProducts(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	list, err := client.Products(ctx)
+	if err != nil {
+		return err
+	}
+	setProducts(list)
+	return emit(productsResult(list))
+}
+
+func runKeys(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	keys, err := client.APIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return emit(apiKeysResult(keys))
+}
+
+func keyNewFlags(fs *flag.FlagSet) {
+	fs.String("name", "", "Name to recognise the key by")
+	fs.String("scopes", "", "Comma separated scopes the key is granted")
+	fs.String("products", "", "Comma separated products the key is limited to")
+	fs.String("expires", "", "Date the key expires, as YYYY-MM-DD")
+	dryRunFlags(fs)
+}
+
+func runKeyNew(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) > 4 {
+		return errUsage
+	}
+	name, scopes, products, expires := stringFlag(fs, "name"), stringFlag(fs, "scopes"), stringFlag(fs, "products"), stringFlag(fs, "expires")
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if len(args) > 1 {
+		scopes = args[1]
+	}
+	if len(args) > 2 {
+		products = args[2]
+	}
+	if len(args) > 3 {
+		expires = args[3]
+	}
+	if name == "" || scopes == "" {
+		return errUsage
+	}
+
+	req := alp.APIKeyRequest{Name: name, Scopes: strings.Split(scopes, ","), ExpiresAt: expires}
+	if products != "" && products != "*" {
+		req.Products = strings.Split(products, ",")
+	}
+	resp, err := target(fs).CreateAPIKey(ctx, req)
+	if err != nil {
+		return err
+	}
+	return emit(apiKeyResponseResult(resp))
+}
+
+func runKeyRevoke(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errUsage
+	}
+
+	keys, err := client.APIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, key := range keys {
+		if key.Id == id {
+			printAPIKey(os.Stderr, key)
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("api key nonexistent")
+	}
+	if !boolFlag(fs, "dry-run") {
+		if err = confirm(fs, "Revoke this API key?"); err != nil {
+			return err
+		}
+	}
+
+	resp, err := target(fs).RevokeAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	return emit(messageResult(resp))
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Candidate is a completion for the word being typed.
+type Candidate struct {
+	Text        string
+	Description string
+}
+
+const (
+	maxRecentKeys = 20
+	productsTTL   = 5 * time.Minute
+)
+
+var known struct {
+	sync.Mutex
+	keys      []string
+	products  []string
+	fetchedAt time.Time
+	fetching  bool
+}
+
+// rememberKey puts key at the front of the recently used keys.
+func rememberKey(key string) {
+	if key == "" {
+		return
+	}
+	known.Lock()
+	defer known.Unlock()
+	known.keys = prepend(known.keys, key, maxRecentKeys)
+}
+
+func rememberProduct(product string) {
+	known.Lock()
+	defer known.Unlock()
+	for _, p := range known.products {
+		if p == product {
+			return
+		}
+	}
+	known.products = append(known.products, product)
+	sort.Strings(known.products)
+}
+
+func setProducts(products []string) {
+	known.Lock()
+	defer known.Unlock()
+	known.products = append([]string(nil), products...)
+	known.fetchedAt = time.Now()
+}
+
+func prepend(list []string, item string, max int) []string {
+	out := []string{item}
+	for _, existing := range list {
+		if existing != item && len(out) < max {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+// products returns the known products, refreshing them from the server in
+// the background when they're stale so completion never waits on it.
+func products() []string {
+	known.Lock()
+	defer known.Unlock()
+	if client != nil && !known.fetching && time.Since(known.fetchedAt) > productsTTL {
+		known.fetching = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			list, err := client.Products(ctx)
+
+			known.Lock()
+			defer known.Unlock()
+			known.fetching = false
+			known.fetchedAt = time.Now()
+			if err == nil {
+				known.products = list
+			}
+		}()
+	}
+	return append([]string(nil), known.products...)
+}
+
+func recentKeys() []string {
+	known.Lock()
+	defer known.Unlock()
+	return append([]string(nil), known.keys...)
+}
+
+// Complete suggests what could come next at the end of line.
+func Complete(line string) []Candidate {
+	args, open, err := split(line)
+	if err != nil {
+		return nil
+	}
+	word := ""
+	if open {
+		word = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+
+	if len(args) == 0 {
+		var out []Candidate
+		for _, cmd := range commands {
+			out = append(out, Candidate{Text: cmd.Name, Description: cmd.Description})
+		}
+		return filter(out, word)
+	}
+
+	cmd, ok := lookup(args[0])
+	if !ok {
+		return nil
+	}
+	fs := cmd.FlagSet()
+	if strings.HasPrefix(word, "-") {
+		var out []Candidate
+		fs.VisitAll(func(f *flag.Flag) {
+			out = append(out, Candidate{Text: "--" + f.Name, Description: f.Usage})
+		})
+		return filter(out, word)
+	}
+
+	// Work out whether the word is a flag's value or which positional
+	// argument it is.
+	position := 0
+	kind := ""
+	for i := 1; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if name != args[i] && !strings.Contains(name, "=") {
+			if f := fs.Lookup(name); f != nil && !isBoolFlag(f) {
+				if i == len(args)-1 {
+					kind = name
+				}
+				i++
+			}
+			continue
+		}
+		position++
+	}
+	if kind == "" && position < len(cmd.Args) {
+		kind = cmd.Args[position]
+	}
+	return filter(candidates(kind), word)
+}
+
+func candidates(kind string) []Candidate {
+	var values []string
+	description := ""
+	switch kind {
+	case "key":
+		values, description = recentKeys(), "Recent key"
+	case "product":
+		values, description = products(), "Product"
+	case "command":
+		var out []Candidate
+		for _, cmd := range commands {
+			out = append(out, Candidate{Text: cmd.Name, Description: cmd.Description})
+		}
+		return out
+	}
+
+	var out []Candidate
+	for _, value := range values {
+		out = append(out, Candidate{Text: value, Description: description})
+	}
+	return out
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func filter(list []Candidate, prefix string) []Candidate {
+	var out []Candidate
+	for _, c := range list {
+		if strings.HasPrefix(c.Text, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
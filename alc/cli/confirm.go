@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"strings"
+)
+
+var errAborted = errors.New("aborted")
+
+// assumeYes answers yes to every confirmation, like --yes on each command.
+var assumeYes bool
+
+func SetAssumeYes(yes bool) {
+	assumeYes = yes
+}
+
+func confirmFlags(fs *flag.FlagSet) {
+	fs.Bool("yes", false, "Don't ask for confirmation")
+	dryRunFlags(fs)
+}
+
+func dryRunFlags(fs *flag.FlagSet) {
+	fs.Bool("dry-run", false, "Have the server validate the request without changing anything")
+}
+
+// confirm asks question on the terminal. Without one it refuses, so a
+// script can't invalidate anything by accident.
+func confirm(fs *flag.FlagSet, question string) error {
+	if assumeYes || boolFlag(fs, "yes") {
+		return nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("confirmation needed, pass --yes to run without a terminal")
+	}
+
+	answer, err := readLine(question + " [y/N]: ")
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "Nothing was changed.")
+	return errAborted
+}
+
+// target returns the client to send a mutating request with, honouring
+// --dry-run.
+func target(fs *flag.FlagSet) *api.Client {
+	if boolFlag(fs, "dry-run") {
+		return client.DryRun()
+	}
+	return client
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats are the values --output accepts. Text is the human readable
+// default.
+var Formats = []string{"text", "table", "json", "yaml", "csv"}
+
+var (
+	format = "text"
+	quiet  bool
+)
+
+// SetOutput picks how results are printed. In quiet mode only license keys,
+// or API key ids, are printed one per line, whatever the format.
+func SetOutput(f string, q bool) error {
+	for _, known := range Formats {
+		if f == known {
+			format = f
+			quiet = q
+			return nil
+		}
+	}
+	return errors.New("unknown output format " + f + ", use one of " + strings.Join(Formats, ", "))
+}
+
+// result is something a command prints, in every format.
+type result struct {
+	// value is encoded as is for json and yaml.
+	value  interface{}
+	header []string
+	rows   [][]string
+	// keys are printed in quiet mode.
+	keys []string
+	text func()
+}
+
+func emit(r result) error {
+	lastResponse = r.value
+	if quiet {
+		for _, key := range r.keys {
+			fmt.Println(key)
+		}
+		return nil
+	}
+
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(r.value, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "yaml":
+		// Going through JSON keeps the field names the same as in json.
+		b, err := json.Marshal(r.value)
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err = yaml.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(out))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(r.header)
+		w.WriteAll(r.rows)
+		return w.Error()
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(r.header, "\t")))
+		for _, row := range r.rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	default:
+		r.text()
+	}
+	return nil
+}
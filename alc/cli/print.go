@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/GreatGodApollo/alp"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func printError(err error) {
+	fmt.Fprintln(os.Stderr, "An error occurred:")
+	fmt.Fprintln(os.Stderr, err.Error())
+}
+
+func printLicense(w io.Writer, license alp.License) {
+	fmt.Fprintln(w, "---")
+	fmt.Fprintf(w, "License Key: %s\n", license.LicenseKey)
+	fmt.Fprintf(w, "Product: %s\n", license.Product)
+	fmt.Fprintf(w, "Valid: %t\n", license.Valid)
+	if license.Suspended {
+		fmt.Fprintln(w, "Suspended: true")
+	}
+	fmt.Fprintf(w, "Email: %s\n", license.Email)
+	if license.MaxVersion > 0 {
+		fmt.Fprintf(w, "Max Version: %d\n", license.MaxVersion)
+	}
+	if license.UpdatesUntil != nil {
+		fmt.Fprintf(w, "Updates Until: %s\n", license.UpdatesUntil.Format("2006-01-02"))
+	}
+	if license.ExpiresAt != nil {
+		fmt.Fprintf(w, "Expires: %s\n", license.ExpiresAt.Format("2006-01-02"))
+	}
+}
+
+func printLicenseResponse(response alp.LicenseResponse) {
+	fmt.Println("---")
+	fmt.Printf("License Key: %s\n", response.LicenseKey)
+	fmt.Printf("Status: %s\n", response.Status)
+	fmt.Printf("Code: %d\n", response.Code)
+	fmt.Printf("Message: %s\n", response.Message)
+}
+
+func printAPIKey(w io.Writer, key alp.APIKey) {
+	fmt.Fprintln(w, "---")
+	fmt.Fprintf(w, "Id: %d\n", key.Id)
+	fmt.Fprintf(w, "Name: %s\n", key.Name)
+	fmt.Fprintf(w, "Prefix: %s\n", key.Prefix)
+	fmt.Fprintf(w, "Scopes: %s\n", strings.Join(key.Scopes, ", "))
+	if len(key.Products) != 0 {
+		fmt.Fprintf(w, "Products: %s\n", strings.Join(key.Products, ", "))
+	}
+	if key.ExpiresAt != nil {
+		fmt.Fprintf(w, "Expires: %s\n", key.ExpiresAt.Format("2006-01-02"))
+	}
+	if key.LastUsedAt != nil {
+		fmt.Fprintf(w, "Last Used: %s\n", key.LastUsedAt.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(w, "Revoked: %t\n", key.Revoked)
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func licensesResult(licenses []alp.License, empty string) result {
+	if licenses == nil {
+		licenses = []alp.License{}
+	}
+	r := result{
+		value:  licenses,
+		header: []string{"key", "product", "valid", "email", "max_version", "updates_until", "expires_at", "entitlements"},
+		text: func() {
+			if len(licenses) == 0 {
+				fmt.Println(empty)
+			}
+			for _, license := range licenses {
+				printLicense(os.Stdout, license)
+			}
+		},
+	}
+	for _, license := range licenses {
+		r.rows = append(r.rows, []string{
+			license.LicenseKey,
+			license.Product,
+			strconv.FormatBool(license.Valid),
+			license.Email,
+			strconv.Itoa(license.MaxVersion),
+			formatDate(license.UpdatesUntil),
+			formatDate(license.ExpiresAt),
+			strings.Join(license.Entitlements, ","),
+		})
+		r.keys = append(r.keys, license.LicenseKey)
+	}
+	return r
+}
+
+func licenseResult(license alp.License) result {
+	r := licensesResult([]alp.License{license}, "")
+	r.value = license
+	return r
+}
+
+func licenseResponseResult(response alp.LicenseResponse) result {
+	return result{
+		value:  response,
+		header: []string{"license_key", "status", "code", "message"},
+		rows:   [][]string{{response.LicenseKey, response.Status, strconv.Itoa(response.Code), response.Message}},
+		keys:   []string{response.LicenseKey},
+		text:   func() { printLicenseResponse(response) },
+	}
+}
+
+type checkOutput struct {
+	LicenseKey string `json:"license_key"`
+	Product    string `json:"product"`
+	Version    string `json:"version,omitempty"`
+	Status     string `json:"status"`
+	Valid      bool   `json:"valid"`
+}
+
+// checkResult has no keys: in quiet mode the exit code is the answer.
+func checkResult(check checkOutput) result {
+	return result{
+		value:  check,
+		header: []string{"license_key", "product", "version", "status", "valid"},
+		rows:   [][]string{{check.LicenseKey, check.Product, check.Version, check.Status, strconv.FormatBool(check.Valid)}},
+		text: func() {
+			fmt.Println("---")
+			fmt.Printf("License Key: %s\n", check.LicenseKey)
+			fmt.Printf("Product: %s\n", check.Product)
+			if check.Version != "" {
+				fmt.Printf("Version: %s\n", check.Version)
+			}
+			fmt.Printf("Valid: %t\n", check.Valid)
+			if check.Status != alp.StatusValid && check.Status != alp.StatusInvalid {
+				fmt.Printf("Status: %s\n", check.Status)
+			}
+		},
+	}
+}
+
+func historyResult(history []alp.HistoryEntry) result {
+	if history == nil {
+		history = []alp.HistoryEntry{}
+	}
+	r := result{
+		value:  history,
+		header: []string{"created_at", "action", "actor", "details"},
+		text: func() {
+			if len(history) == 0 {
+				fmt.Println("Nothing recorded!")
+			}
+			for _, entry := range history {
+				line := entry.CreatedAt.Local().Format("2006-01-02 15:04:05") + "  " + entry.Action + " by " + entry.Actor
+				if entry.Details != "" {
+					line += ": " + entry.Details
+				}
+				fmt.Println(line)
+			}
+		},
+	}
+	for _, entry := range history {
+		r.rows = append(r.rows, []string{entry.CreatedAt.Format(time.RFC3339), entry.Action, entry.Actor, entry.Details})
+	}
+	return r
+}
+
+func apiKeysResult(keys []alp.APIKey) result {
+	if keys == nil {
+		keys = []alp.APIKey{}
+	}
+	r := result{
+		value:  keys,
+		header: []string{"id", "name", "prefix", "scopes", "products", "expires_at", "last_used_at", "revoked"},
+		text: func() {
+			if len(keys) == 0 {
+				fmt.Println("No API keys found!")
+			}
+			for _, key := range keys {
+				printAPIKey(os.Stdout, key)
+			}
+		},
+	}
+	for _, key := range keys {
+		lastUsed := ""
+		if key.LastUsedAt != nil {
+			lastUsed = key.LastUsedAt.Format("2006-01-02 15:04:05")
+		}
+		r.rows = append(r.rows, []string{
+			strconv.Itoa(key.Id),
+			key.Name,
+			key.Prefix,
+			strings.Join(key.Scopes, ","),
+			strings.Join(key.Products, ","),
+			formatDate(key.ExpiresAt),
+			lastUsed,
+			strconv.FormatBool(key.Revoked),
+		})
+		r.keys = append(r.keys, strconv.Itoa(key.Id))
+	}
+	return r
+}
+
+// apiKeyResponseResult prints the new key itself in quiet mode, since it
+// can't be fetched again.
+func apiKeyResponseResult(resp alp.APIKeyResponse) result {
+	r := apiKeysResult([]alp.APIKey{resp.APIKey})
+	r.value = resp
+	r.header = append([]string{"key"}, r.header...)
+	r.rows[0] = append([]string{resp.Key}, r.rows[0]...)
+	r.keys = []string{resp.Key}
+	r.text = func() {
+		printAPIKey(os.Stdout, resp.APIKey)
+		fmt.Printf("Key: %s\n", resp.Key)
+		fmt.Println("Store this key now, it will not be shown again.")
+	}
+	return r
+}
+
+func messageResult(resp alp.BasicResponse) result {
+	return result{
+		value:  resp,
+		header: []string{"status", "code", "message"},
+		rows:   [][]string{{resp.Status, strconv.Itoa(resp.Code), resp.Message}},
+		text:   func() { fmt.Println(resp.Message) },
+	}
+}
+
+func productsResult(products []string) result {
+	if products == nil {
+		products = []string{}
+	}
+	r := result{
+		value:  products,
+		header: []string{"product"},
+		keys:   products,
+		text: func() {
+			if len(products) == 0 {
+				fmt.Println("No products found!")
+			}
+			for _, product := range products {
+				fmt.Println(product)
+			}
+		},
+	}
+	for _, product := range products {
+		r.rows = append(r.rows, []string{product})
+	}
+	return r
+}
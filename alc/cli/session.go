@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alc/credentials"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Session is the profile alc is connected with.
+type Session struct {
+	Profile  string
+	Profiles []string
+	Store    *credentials.Store
+	// Connect builds a client for the profile, with stored credentials
+	// when there are any.
+	Connect func(credentials.Credentials) (*api.Client, error)
+}
+
+var session Session
+
+func SetSession(s Session) {
+	session = s
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readLine(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readSecret reads without echo from a terminal, and a plain line
+// otherwise.
+func readSecret(label string) (string, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return readLine(label)
+	}
+	fmt.Fprint(os.Stderr, label)
+	b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+func readPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv("ALC_PASSPHRASE"); ok {
+		return passphrase, nil
+	}
+	passphrase, err := readSecret("Passphrase to encrypt the credentials: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase can't be empty")
+	}
+	confirm, err := readSecret("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != passphrase {
+		return "", errors.New("passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+func loginFlags(fs *flag.FlagSet) {
+	fs.Bool("api-key", false, "Log in with an API key instead of a username and password")
+}
+
+func runLogin(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	var creds credentials.Credentials
+	var err error
+	if boolFlag(fs, "api-key") {
+		if creds.APIKey, err = readSecret("API key: "); err != nil {
+			return err
+		}
+	} else {
+		if creds.Username, err = readLine("Username: "); err != nil {
+			return err
+		}
+		if creds.Password, err = readSecret("Password: "); err != nil {
+			return err
+		}
+	}
+	if creds == (credentials.Credentials{}) {
+		return errors.New("no credentials given")
+	}
+
+	passphrase, err := readPassphrase()
+	if err != nil {
+		return err
+	}
+	if err = session.Store.Save(session.Profile, passphrase, creds); err != nil {
+		return err
+	}
+	if client, err = session.Connect(creds); err != nil {
+		return err
+	}
+	fmt.Printf("Logged in to profile %s, credentials saved to %s\n", session.Profile, session.Store.Path())
+	return nil
+}
+
+func runLogout(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	err := session.Store.Delete(session.Profile)
+	if errors.Is(err, credentials.ErrNotFound) {
+		fmt.Printf("Profile %s has no stored credentials\n", session.Profile)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if client, err = session.Connect(credentials.Credentials{}); err != nil {
+		return err
+	}
+	fmt.Printf("Logged out of profile %s\n", session.Profile)
+	return nil
+}
+
+type profileOutput struct {
+	Name     string `json:"name"`
+	Current  bool   `json:"current"`
+	LoggedIn bool   `json:"logged_in"`
+}
+
+func runProfiles(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	var list []profileOutput
+	for _, name := range session.Profiles {
+		loggedIn, err := session.Store.Has(name)
+		if err != nil {
+			return err
+		}
+		list = append(list, profileOutput{Name: name, Current: name == session.Profile, LoggedIn: loggedIn})
+	}
+
+	r := result{
+		value:  list,
+		header: []string{"name", "current", "logged_in"},
+		text: func() {
+			for _, p := range list {
+				marker := " "
+				if p.Current {
+					marker = "*"
+				}
+				status := ""
+				if p.LoggedIn {
+					status = " (logged in)"
+				}
+				fmt.Printf("%s %s%s\n", marker, p.Name, status)
+			}
+		},
+	}
+	for _, p := range list {
+		r.rows = append(r.rows, []string{p.Name, strconv.FormatBool(p.Current), strconv.FormatBool(p.LoggedIn)})
+		r.keys = append(r.keys, p.Name)
+	}
+	return emit(r)
+}
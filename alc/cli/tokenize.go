@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var errUnterminated = errors.New("unterminated quote")
+
+// Split breaks a command line into arguments like a shell would: runs of
+// whitespace separate arguments, single quotes keep everything literally,
+// double quotes keep whitespace but allow \" and \\, and a backslash
+// outside quotes escapes the next character.
+func Split(line string) ([]string, error) {
+	args, _, err := split(line)
+	return args, err
+}
+
+// split also reports whether the line ends inside an argument, which
+// completion needs to know.
+func split(line string) (args []string, open bool, err error) {
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, true, errUnterminated
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, inArg, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alc/credentials"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Settings are looked up in the environment (ALC_API_BASEURL for
+// api.baseurl), then the selected profile, then the top level of the
+// config file.
+var profile string
+
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "alc")
+}
+
+// historyPath is where the profile's prompt history is kept, or empty when
+// history is disabled.
+func historyPath() string {
+	if !cast.ToBool(setting("history.enabled")) || configDir() == "" {
+		return ""
+	}
+	return filepath.Join(configDir(), "history", profile)
+}
+
+// auditPath is where mutating commands are logged, or empty when the audit
+// log is disabled.
+func auditPath() string {
+	if !cast.ToBool(setting("audit.enabled")) {
+		return ""
+	}
+	if file := settingString("audit.file"); file != "" {
+		return file
+	}
+	return filepath.Join(configDir(), "audit.log")
+}
+
+func loadConfig() error {
+	viper.AddConfigPath(".")
+	if dir := configDir(); dir != "" {
+		viper.AddConfigPath(dir)
+	}
+	viper.SetConfigName("alc")
+	viper.SetConfigType("json")
+
+	// Api Settings
+	viper.SetDefault("api.baseurl", "http://localhost:8080")
+	viper.SetDefault("api.endpoints", []string{})
+	viper.SetDefault("api.retries", api.DefaultRetryPolicy.MaxAttempts-1)
+	viper.SetDefault("api.public_key", "")
+	viper.SetDefault("api.timeout", "30s")
+
+	// Auth Settings
+	viper.SetDefault("auth.username", "")
+	viper.SetDefault("auth.password", "")
+	viper.SetDefault("auth.apikey", "")
+
+	// Output Settings
+	viper.SetDefault("output", "text")
+
+	// Profile Settings
+	viper.SetDefault("profile", "default")
+
+	// History Settings
+	viper.SetDefault("history.enabled", true)
+	viper.SetDefault("audit.enabled", false)
+	viper.SetDefault("audit.file", "")
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func envName(key string) string {
+	return "ALC_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+func setting(key string) interface{} {
+	if value, ok := os.LookupEnv(envName(key)); ok {
+		return value
+	}
+	if viper.IsSet("profiles." + profile + "." + key) {
+		return viper.Get("profiles." + profile + "." + key)
+	}
+	return viper.Get(key)
+}
+
+func settingString(key string) string {
+	return cast.ToString(setting(key))
+}
+
+func settingInt(key string) int {
+	return cast.ToInt(setting(key))
+}
+
+func settingStrings(key string) []string {
+	if s, ok := setting(key).(string); ok {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, ",")
+	}
+	return cast.ToStringSlice(setting(key))
+}
+
+// selectProfile picks the profile from the flag, ALC_PROFILE or the config,
+// in that order. A profile must be configured or have stored credentials.
+func selectProfile(flagValue string, store *credentials.Store) error {
+	profile = flagValue
+	if profile == "" {
+		profile = os.Getenv("ALC_PROFILE")
+	}
+	if profile == "" {
+		profile = viper.GetString("profile")
+	}
+
+	if profile == "default" || viper.IsSet("profiles."+profile) {
+		return nil
+	}
+	if ok, err := store.Has(profile); err != nil || ok {
+		return err
+	}
+	return errors.New("unknown profile " + profile)
+}
+
+// profiles lists the configured profiles and those with stored credentials.
+func profiles(store *credentials.Store) []string {
+	seen := map[string]bool{"default": true}
+	for name := range viper.GetStringMap("profiles") {
+		seen[name] = true
+	}
+	stored, _ := store.Profiles()
+	for _, name := range stored {
+		seen[name] = true
+	}
+
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// storedCredentials unlocks the profile's stored credentials, with the
+// passphrase from ALC_PASSPHRASE or the terminal. Credentials in the
+// environment take precedence, so the store isn't opened then.
+func storedCredentials(store *credentials.Store) (credentials.Credentials, error) {
+	for _, key := range []string{"auth.apikey", "auth.username"} {
+		if _, ok := os.LookupEnv(envName(key)); ok {
+			return credentials.Credentials{}, nil
+		}
+	}
+	if ok, err := store.Has(profile); err != nil || !ok {
+		return credentials.Credentials{}, err
+	}
+
+	passphrase, ok := os.LookupEnv("ALC_PASSPHRASE")
+	if !ok {
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			return credentials.Credentials{}, errors.New("stored credentials are locked, set ALC_PASSPHRASE")
+		}
+		fmt.Fprintf(os.Stderr, "Passphrase for profile %s: ", profile)
+		b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return credentials.Credentials{}, err
+		}
+		passphrase = string(b)
+	}
+	return store.Load(profile, passphrase)
+}
+
+// newClient builds a client from the settings, using stored credentials
+// over any in the config file.
+func newClient(stored credentials.Credentials) (*api.Client, error) {
+	opts := []api.Option{
+		api.WithBaseURL(settingString("api.baseurl")),
+		api.WithEndpoints(settingStrings("api.endpoints")...),
+		api.WithTimeout(cast.ToDuration(setting("api.timeout"))),
+	}
+	retry := api.DefaultRetryPolicy
+	// api.retries counts the tries after the first.
+	retry.MaxAttempts = settingInt("api.retries") + 1
+	opts = append(opts, api.WithRetryPolicy(retry))
+
+	creds := credentials.Credentials{
+		Username: settingString("auth.username"),
+		Password: settingString("auth.password"),
+		APIKey:   settingString("auth.apikey"),
+	}
+	if stored != (credentials.Credentials{}) {
+		creds = stored
+	}
+	if creds.Username != "" {
+		opts = append(opts, api.WithBasicAuth(creds.Username, creds.Password))
+	}
+	if creds.APIKey != "" {
+		opts = append(opts, api.WithAPIKey(creds.APIKey))
+	}
+
+	if key := settingString("api.public_key"); key != "" {
+		pub, err := api.ParsePublicKey(key)
+		if err != nil {
+			return nil, errors.New("invalid api.public_key: " + err.Error())
+		}
+		opts = append(opts, api.WithPublicKey(pub))
+	}
+	return api.NewClient(opts...), nil
+}
@@ -0,0 +1,187 @@
+// Package credentials keeps alc's logins in a file, encrypted with a
+// passphrase, so they don't sit in the config in plaintext.
+//
+// Each profile is sealed on its own with AES-256-GCM under a key derived
+// from its passphrase with scrypt. Which profiles have credentials can be
+// seen without a passphrase.
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/scrypt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrNotFound        = errors.New("no stored credentials for this profile")
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted credentials")
+)
+
+type Credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	APIKey   string `json:"apikey,omitempty"`
+}
+
+type sealed struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+type file struct {
+	Profiles map[string]sealed `json:"profiles"`
+}
+
+type Store struct {
+	path string
+}
+
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Profiles lists the profiles with stored credentials.
+func (s *Store) Profiles() ([]string, error) {
+	f, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Store) Has(profile string) (bool, error) {
+	f, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	_, ok := f.Profiles[profile]
+	return ok, nil
+}
+
+func (s *Store) Load(profile, passphrase string) (Credentials, error) {
+	f, err := s.read()
+	if err != nil {
+		return Credentials{}, err
+	}
+	entry, ok := f.Profiles[profile]
+	if !ok {
+		return Credentials{}, ErrNotFound
+	}
+
+	gcm, err := newGCM(passphrase, entry.Salt)
+	if err != nil {
+		return Credentials{}, err
+	}
+	plain, err := gcm.Open(nil, entry.Nonce, entry.Data, []byte(profile))
+	if err != nil {
+		return Credentials{}, ErrWrongPassphrase
+	}
+
+	var creds Credentials
+	if err = json.Unmarshal(plain, &creds); err != nil {
+		return Credentials{}, ErrWrongPassphrase
+	}
+	return creds, nil
+}
+
+func (s *Store) Save(profile, passphrase string, creds Credentials) error {
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	entry := sealed{Salt: make([]byte, 16)}
+	if _, err = rand.Read(entry.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, entry.Salt)
+	if err != nil {
+		return err
+	}
+	entry.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(entry.Nonce); err != nil {
+		return err
+	}
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	// The profile name is authenticated so entries can't be swapped.
+	entry.Data = gcm.Seal(nil, entry.Nonce, plain, []byte(profile))
+
+	f.Profiles[profile] = entry
+	return s.write(f)
+}
+
+func (s *Store) Delete(profile string) error {
+	f, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Profiles[profile]; !ok {
+		return ErrNotFound
+	}
+	delete(f.Profiles, profile)
+	return s.write(f)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *Store) read() (file, error) {
+	f := file{Profiles: map[string]sealed{}}
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return f, err
+	}
+	if err = json.Unmarshal(b, &f); err != nil {
+		return f, err
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]sealed{}
+	}
+	return f, nil
+}
+
+func (s *Store) write(f file) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -4,22 +4,28 @@ go 1.14
 
 require (
 	github.com/GreatGodApollo/ala v0.0.0-20200405212129-5f2393fc8e50
+	github.com/GreatGodApollo/alp v0.0.0
 	github.com/c-bata/go-prompt v0.2.3
 	github.com/fsnotify/fsnotify v1.4.9 // indirect
-	github.com/go-resty/resty/v2 v2.2.0
+	github.com/gdamore/tcell v1.4.0
 	github.com/mattn/go-colorable v0.1.6 // indirect
-	github.com/mattn/go-runewidth v0.0.9 // indirect
+	github.com/mattn/go-runewidth v0.0.9
 	github.com/mattn/go-tty v0.0.3 // indirect
 	github.com/mitchellh/mapstructure v1.2.2 // indirect
 	github.com/pelletier/go-toml v1.7.0 // indirect
 	github.com/pkg/term v0.0.0-20190109203006-aa71e9d9e942 // indirect
 	github.com/spf13/afero v1.2.2 // indirect
-	github.com/spf13/cast v1.3.1 // indirect
+	github.com/spf13/cast v1.3.1
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/spf13/viper v1.6.2
+	golang.org/x/crypto v0.0.0-20200403201458-baeed622b8d8
 	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e // indirect
 	golang.org/x/sys v0.0.0-20200331124033-c3d80250170d // indirect
-	golang.org/x/text v0.3.2 // indirect
 	gopkg.in/ini.v1 v1.55.0 // indirect
+	gopkg.in/yaml.v2 v2.2.8
 )
+
+replace github.com/GreatGodApollo/ala => ../ala
+
+replace github.com/GreatGodApollo/alp => ../alp
@@ -1,33 +1,120 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"github.com/GreatGodApollo/alc/cli"
+	"github.com/GreatGodApollo/alc/credentials"
 	"github.com/GreatGodApollo/alc/prompt"
-	"github.com/go-resty/resty/v2"
 	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
 )
 
-func init() {
-	viper.AddConfigPath(".")
-	viper.SetConfigName("alc")
-	viper.SetConfigType("json")
+const usage = `usage: alc [options]                       Start the interactive prompt
+       alc [options] <command> [args...]   Run one command, see alc help
+       alc [options] -f <script|->         Run commands from a file or standard input
 
-	// Api Settings
-	viper.SetDefault("api.baseurl", "http://localhost:8080")
+options:
+  -p, --profile <name>    Connection profile to use, see alc profiles
+  -o, --output <format>   text, table, json, yaml or csv
+  -q, --quiet             Only print license keys, or API key ids
+  -y, --yes               Don't ask before invalidating or revoking
 
-	// Auth Settings
-	viper.SetDefault("auth.username", "username")
-	viper.SetDefault("auth.password", "password")
+Settings can be overridden with ALC_ variables, e.g. ALC_API_BASEURL or
+ALC_AUTH_APIKEY. ALC_PASSPHRASE unlocks credentials stored by alc login.
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file", viper.ConfigFileUsed())
-	} else {
-		panic("Could not load configuration file: " + err.Error())
-	}
-}
+exit codes: 0 ok, 1 error, 2 usage, 3 license not valid or nonexistent`
 
 func main() {
-	restyCli := resty.New()
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not load configuration file:", err)
+		os.Exit(cli.ExitError)
+	}
+
+	fs := flag.NewFlagSet("alc", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	var output, script, profileFlag string
+	var quiet, yes bool
+	fs.StringVar(&output, "output", "", "")
+	fs.StringVar(&output, "o", "", "")
+	fs.BoolVar(&quiet, "quiet", false, "")
+	fs.BoolVar(&quiet, "q", false, "")
+	fs.StringVar(&script, "file", "", "")
+	fs.StringVar(&script, "f", "", "")
+	fs.StringVar(&profileFlag, "profile", "", "")
+	fs.StringVar(&profileFlag, "p", "", "")
+	fs.BoolVar(&yes, "yes", false, "")
+	fs.BoolVar(&yes, "y", false, "")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(cli.ExitOK)
+		}
+		os.Exit(cli.ExitUsage)
+	}
+
+	store := credentials.Open(filepath.Join(configDir(), "credentials.json"))
+	if err := selectProfile(profileFlag, store); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitUsage)
+	}
+	if output == "" {
+		output = settingString("output")
+	}
+	if err := cli.SetOutput(output, quiet); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitUsage)
+	}
+
+	args := fs.Args()
+
+	// There's no need to unlock credentials for commands like login.
+	var stored credentials.Credentials
+	var err error
+	if script != "" || len(args) == 0 || !cli.IsLocal(args[0]) {
+		if stored, err = storedCredentials(store); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not unlock credentials:", err)
+		}
+	}
+	client, err := newClient(stored)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(cli.ExitError)
+	}
+	cli.SetClient(client)
+	cli.SetSession(cli.Session{
+		Profile:  profile,
+		Profiles: profiles(store),
+		Store:    store,
+		Connect:  newClient,
+	})
+	cli.SetAudit(auditPath())
+	cli.SetAssumeYes(yes)
+	ctx := context.Background()
+
+	switch {
+	case script != "":
+		if len(args) != 0 {
+			fs.Usage()
+			os.Exit(cli.ExitUsage)
+		}
+		os.Exit(cli.RunScriptFile(ctx, script))
+	case len(args) > 0:
+		code, _ := cli.Execute(ctx, args)
+		os.Exit(code)
+	case !isTerminal(os.Stdin):
+		os.Exit(cli.RunScript(ctx, os.Stdin))
+	default:
+		if file := viper.ConfigFileUsed(); file != "" {
+			fmt.Println("Using config file", file)
+		}
+		fmt.Println("Using profile", profile)
+		prompt.RunPrompt(client, historyPath())
+	}
+}
 
-	prompt.RunPrompt(restyCli)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
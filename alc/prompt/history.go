@@ -0,0 +1,84 @@
+package prompt
+
+import (
+	"bufio"
+	"github.com/c-bata/go-prompt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory is how many lines are loaded back from the history file.
+const maxHistory = 1000
+
+var (
+	historyPath string
+	history     []string
+)
+
+func loadHistory(path string) {
+	historyPath = path
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+}
+
+func appendHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	history = append(history, line)
+	if historyPath == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
+
+var search struct {
+	query string
+	match string
+	index int
+}
+
+// reverseSearch replaces the input with the latest history line containing
+// it. Pressing it again while the match is shown goes further back.
+func reverseSearch(buf *prompt.Buffer) {
+	text := buf.Text()
+	if text == "" || text != search.match {
+		search.query = text
+		search.index = len(history)
+	}
+
+	for i := search.index - 1; i >= 0; i-- {
+		if strings.Contains(history[i], search.query) && history[i] != text {
+			search.index = i
+			search.match = history[i]
+			buf.CursorRight(len([]rune(buf.Document().TextAfterCursor())))
+			buf.DeleteBeforeCursor(len([]rune(text)))
+			buf.InsertText(history[i], false, true)
+			return
+		}
+	}
+}
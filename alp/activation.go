@@ -0,0 +1,29 @@
+package alp
+
+import "time"
+
+// Activation is a machine a license is in use on, identified by a
+// fingerprint from ala/fingerprint.
+type Activation struct {
+	Id          int       `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+type Activations struct {
+	Code           int          `json:"code"`
+	MaxActivations int          `json:"max_activations"`
+	Activations    []Activation `json:"activations"`
+}
+
+type ActivationRequest struct {
+	Fingerprint string `json:"fingerprint" binding:"required"`
+}
+
+type ActivationResponse struct {
+	Activation Activation `json:"activation"`
+	Status     string     `json:"status"`
+	Message    string     `json:"message"`
+	Code       int        `json:"code"`
+}
@@ -0,0 +1,40 @@
+package alp
+
+import "time"
+
+type APIKey struct {
+	Id         int        `json:"id"`
+	TenantId   int        `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Products   []string   `json:"products,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Revoked    bool       `json:"revoked"`
+}
+
+type APIKeys struct {
+	Code int      `json:"code"`
+	Keys []APIKey `json:"keys"`
+}
+
+type APIKeyRequest struct {
+	Name      string   `json:"name" form:"name" binding:"required"`
+	Scopes    []string `json:"scopes" form:"scopes" binding:"required"`
+	Products  []string `json:"products" form:"products"`
+	ExpiresAt string   `json:"expires_at" form:"expires_at"`
+}
+
+type APIKeyResponse struct {
+	Key     string `json:"key"`
+	APIKey  APIKey `json:"api_key"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+type RevokeRequest struct {
+	Id int `json:"id" form:"id" binding:"required"`
+}
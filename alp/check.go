@@ -0,0 +1,9 @@
+package alp
+
+type CheckRequest struct {
+	Key         string `json:"key" form:"key" binding:"required"`
+	Product     string `json:"product" form:"product" binding:"required"`
+	Version     string `json:"version" form:"version"`
+	ReleaseDate string `json:"release_date" form:"release_date"`
+	Nonce       string `json:"nonce" form:"nonce"`
+}
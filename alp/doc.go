@@ -0,0 +1,8 @@
+// Package alp is the wire protocol spoken between als and its clients: the
+// request and response bodies of the HTTP API, and the statuses and
+// messages that clients act on.
+package alp
+
+// Version is the newest version of the HTTP API these types describe, as
+// in /api/v2. Version 1 is still served.
+const Version = 2
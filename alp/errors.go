@@ -0,0 +1,29 @@
+package alp
+
+// Error codes say, in a way programs can rely on, why a request failed.
+// Unlike messages they never change wording.
+const (
+	ErrorInvalidRequest   = "invalid_request"
+	ErrorInvalidKeyFormat = "invalid_key_format"
+	ErrorLicenseNotFound  = "license_not_found"
+	ErrorProductMismatch  = "product_mismatch"
+	ErrorUnauthorized     = "unauthorized"
+	ErrorForbidden        = "forbidden"
+	ErrorNotFound         = "not_found"
+	ErrorConflict         = "conflict"
+	// ErrorPreconditionFailed means the If-Match header no longer matches
+	// the resource's ETag; fetch it again and retry.
+	ErrorPreconditionFailed  = "precondition_failed"
+	ErrorActivationsExceeded = "activations_exceeded"
+	ErrorRateLimited         = "rate_limited"
+	ErrorInternal            = "internal"
+)
+
+// ErrorResponse is the body of every failed request. Code repeats the HTTP
+// status.
+type ErrorResponse struct {
+	Status  string `json:"status"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
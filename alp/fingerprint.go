@@ -0,0 +1,82 @@
+package alp
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// FingerprintVersion starts every fingerprint. A fingerprint's string form
+// is
+//
+//	fp2.<machine-id>.<product-uuid>.<mac>
+//
+// where each component is 16 lowercase hex characters, or "-" when it
+// couldn't be read, so it is at most 54 characters long. ala/fingerprint
+// makes them.
+const FingerprintVersion = "fp2"
+
+const fingerprintMissing = "-"
+
+var ErrInvalidFingerprint = errors.New("invalid fingerprint")
+
+// Fingerprint holds the hashed components that identify a machine. Empty
+// fields are components that couldn't be read.
+type Fingerprint struct {
+	MachineID   string
+	ProductUUID string
+	MAC         string
+}
+
+func ParseFingerprint(s string) (Fingerprint, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 || parts[0] != FingerprintVersion {
+		return Fingerprint{}, ErrInvalidFingerprint
+	}
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == fingerprintMissing {
+			parts[i] = ""
+			continue
+		}
+		if len(parts[i]) != 16 || strings.ToLower(parts[i]) != parts[i] {
+			return Fingerprint{}, ErrInvalidFingerprint
+		}
+		if _, err := hex.DecodeString(parts[i]); err != nil {
+			return Fingerprint{}, ErrInvalidFingerprint
+		}
+	}
+	return Fingerprint{MachineID: parts[1], ProductUUID: parts[2], MAC: parts[3]}, nil
+}
+
+func (f Fingerprint) String() string {
+	return strings.Join([]string{FingerprintVersion, orMissing(f.MachineID), orMissing(f.ProductUUID), orMissing(f.MAC)}, ".")
+}
+
+// Match reports whether f and other are the same machine: at least one
+// component agrees and at most one differs. A component known on only one
+// side counts as differing. This way replacing a network card or
+// reinstalling the OS doesn't make a machine look new.
+func (f Fingerprint) Match(other Fingerprint) bool {
+	same, differ := 0, 0
+	for _, pair := range [][2]string{
+		{f.MachineID, other.MachineID},
+		{f.ProductUUID, other.ProductUUID},
+		{f.MAC, other.MAC},
+	} {
+		switch {
+		case pair[0] == "" && pair[1] == "":
+		case pair[0] == pair[1]:
+			same++
+		default:
+			differ++
+		}
+	}
+	return same >= 1 && differ <= 1
+}
+
+func orMissing(s string) string {
+	if s == "" {
+		return fingerprintMissing
+	}
+	return s
+}
@@ -0,0 +1,3 @@
+module github.com/GreatGodApollo/alp
+
+go 1.14
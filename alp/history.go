@@ -0,0 +1,16 @@
+package alp
+
+import "time"
+
+type HistoryEntry struct {
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type History struct {
+	LicenseKey string         `json:"key"`
+	History    []HistoryEntry `json:"history"`
+	Code       int            `json:"code"`
+}
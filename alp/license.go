@@ -0,0 +1,59 @@
+package alp
+
+import "time"
+
+type License struct {
+	Id           int        `json:"id"`
+	LicenseKey   string     `json:"key"`
+	Product      string     `json:"product"`
+	Email        string     `json:"email"`
+	Valid        bool       `json:"valid"`
+	Suspended    bool       `json:"suspended,omitempty"`
+	MaxVersion   int        `json:"max_version,omitempty"`
+	UpdatesUntil *time.Time `json:"updates_until,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Entitlements []string   `json:"entitlements,omitempty"`
+	// MaxActivations limits how many machines may be activated at once.
+	// Zero means no limit.
+	MaxActivations int `json:"max_activations,omitempty"`
+	// Revision counts changes to the license. It is sent as an ETag rather
+	// than in the body.
+	Revision int `json:"-"`
+	Code     int `json:"code"`
+}
+
+type Licenses struct {
+	Code     int       `json:"code"`
+	Licenses []License `json:"licenses"`
+}
+
+type BasicRequest struct {
+	Key string `json:"key" form:"key" binding:"required"`
+}
+
+type LicenseRequest struct {
+	Email        string   `json:"email" form:"email" binding:"required"`
+	Product      string   `json:"product" form:"product" binding:"required"`
+	MaxVersion   int      `json:"max_version" form:"max_version"`
+	UpdatesUntil string   `json:"updates_until" form:"updates_until"`
+	ExpiresAt    string   `json:"expires_at" form:"expires_at"`
+	Entitlements []string `json:"entitlements" form:"entitlements"`
+}
+
+// LicenseCreate is the body of POST /api/v2/licenses.
+type LicenseCreate struct {
+	LicenseRequest
+	MaxActivations int `json:"max_activations"`
+}
+
+// LicensePatch is the body of PATCH /api/v2/licenses/{id}. Fields left out
+// are not changed; empty dates clear them.
+type LicensePatch struct {
+	Email          *string   `json:"email"`
+	Suspended      *bool     `json:"suspended"`
+	MaxVersion     *int      `json:"max_version"`
+	UpdatesUntil   *string   `json:"updates_until"`
+	ExpiresAt      *string   `json:"expires_at"`
+	Entitlements   *[]string `json:"entitlements"`
+	MaxActivations *int      `json:"max_activations"`
+}
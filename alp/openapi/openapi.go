@@ -0,0 +1,172 @@
+// Package openapi holds the OpenAPI document for the als HTTP API and
+// validates requests and responses against it.
+package openapi
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas       map[string]*Schema      `json:"schemas"`
+	Parameters    map[string]*Parameter   `json:"parameters"`
+	RequestBodies map[string]*RequestBody `json:"requestBodies"`
+	Responses     map[string]*Response    `json:"responses"`
+}
+
+type Operation struct {
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Ref      string                `json:"$ref"`
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the part of a JSON schema the document uses.
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Nullable   bool               `json:"nullable"`
+	Enum       []string           `json:"enum"`
+	Pattern    string             `json:"pattern"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	Minimum    *float64           `json:"minimum"`
+	Required   []string           `json:"required"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+}
+
+var doc Document
+
+func init() {
+	if err := json.Unmarshal([]byte(spec), &doc); err != nil {
+		panic("openapi: invalid document: " + err.Error())
+	}
+}
+
+// JSON returns the document as served at /openapi.json.
+func JSON() []byte {
+	return []byte(spec)
+}
+
+// Path turns a gin route like /api/v1/all/:product into the document's
+// /api/v1/all/{product}.
+func Path(route string) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Documented reports whether the document describes method on route, a gin
+// route like /api/v1/all/:product.
+func Documented(method, route string) bool {
+	_, ok := operation(method, Path(route))
+	return ok
+}
+
+// Match finds the document path a request for path, like /api/v1/all/prod,
+// goes to, and the path parameters in it. A literal segment beats a
+// parameter, as it does in gin.
+func Match(method, path string) (string, map[string]string, bool) {
+	var candidates []string
+	for candidate, ops := range doc.Paths {
+		if _, ok := ops[strings.ToLower(method)]; ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.Strings(candidates)
+
+	segments := strings.Split(path, "/")
+	best, bestParams := "", map[string]string(nil)
+	for _, candidate := range candidates {
+		parts := strings.Split(candidate, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				params[part[1:len(part)-1]] = segments[i]
+			} else if part != segments[i] {
+				params = nil
+				break
+			}
+		}
+		if params != nil && (bestParams == nil || len(params) < len(bestParams)) {
+			best, bestParams = candidate, params
+		}
+	}
+	return best, bestParams, bestParams != nil
+}
+
+// operation finds the operation for method on path, if the document has
+// one.
+func operation(method, path string) (*Operation, bool) {
+	op, ok := doc.Paths[path][strings.ToLower(method)]
+	return op, ok
+}
+
+func (p *Parameter) resolve() *Parameter {
+	if p.Ref != "" {
+		return doc.Components.Parameters[refName(p.Ref)]
+	}
+	return p
+}
+
+func (b *RequestBody) resolve() *RequestBody {
+	if b.Ref != "" {
+		return doc.Components.RequestBodies[refName(b.Ref)]
+	}
+	return b
+}
+
+func (r *Response) resolve() *Response {
+	if r.Ref != "" {
+		return doc.Components.Responses[refName(r.Ref)]
+	}
+	return r
+}
+
+func (s *Schema) resolve() *Schema {
+	if s.Ref != "" {
+		return doc.Components.Schemas[refName(s.Ref)]
+	}
+	return s
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
@@ -0,0 +1,796 @@
+package openapi
+
+// spec describes the HTTP API. Requests are validated against it, so a
+// route's parameters and body must be declared here before it can be used.
+const spec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Apollo's Licensing Server",
+    "version": "2"
+  },
+  "security": [
+    {"basicAuth": []},
+    {"apiKey": []},
+    {"bearerAuth": []}
+  ],
+  "paths": {
+    "/": {
+      "get": {
+        "summary": "Say hello",
+        "security": [],
+        "responses": {
+          "200": {"description": "The server is up", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BasicResponse"}}}}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "security": [],
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    },
+    "/api/v1/create": {
+      "post": {
+        "summary": "Create a license",
+        "x-scope": "licenses:write",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"$ref": "#/components/requestBodies/LicenseRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/LicenseResponse"},
+          "201": {"$ref": "#/components/responses/LicenseResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/invalidate": {
+      "post": {
+        "summary": "Invalidate a license",
+        "x-scope": "licenses:invalidate",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"$ref": "#/components/requestBodies/BasicRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/LicenseResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/suspend": {
+      "post": {
+        "summary": "Suspend a license until it is resumed",
+        "x-scope": "licenses:invalidate",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"$ref": "#/components/requestBodies/BasicRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/LicenseResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/resume": {
+      "post": {
+        "summary": "Resume a suspended license",
+        "x-scope": "licenses:invalidate",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"$ref": "#/components/requestBodies/BasicRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/LicenseResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/specific": {
+      "post": {
+        "summary": "Get a license",
+        "x-scope": "licenses:read",
+        "requestBody": {"$ref": "#/components/requestBodies/BasicRequest"},
+        "responses": {
+          "200": {"description": "The license", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/License"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/history": {
+      "post": {
+        "summary": "List what has been done to a license",
+        "x-scope": "licenses:read",
+        "requestBody": {"$ref": "#/components/requestBodies/BasicRequest"},
+        "responses": {
+          "200": {"description": "The license's history, oldest first", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/History"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/all/{product}": {
+      "get": {
+        "summary": "List a product's valid licenses",
+        "x-scope": "licenses:read",
+        "parameters": [
+          {"name": "product", "in": "path", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "200": {"description": "The licenses, with encrypted keys", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Licenses"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/products": {
+      "get": {
+        "summary": "List products with licenses",
+        "x-scope": "licenses:read",
+        "responses": {
+          "200": {"description": "The products the caller may see", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Products"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/keys/create": {
+      "post": {
+        "summary": "Mint an API key",
+        "x-scope": "keys:admin",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"required": true, "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/APIKeyRequest"}},
+          "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/APIKeyRequest"}}
+        }},
+        "responses": {
+          "200": {"$ref": "#/components/responses/APIKeyResponse"},
+          "201": {"$ref": "#/components/responses/APIKeyResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/keys/revoke": {
+      "post": {
+        "summary": "Revoke an API key granting no more than the caller holds",
+        "x-scope": "keys:admin",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"$ref": "#/components/requestBodies/IdRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/BasicResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/keys/all": {
+      "get": {
+        "summary": "List API keys",
+        "x-scope": "keys:admin",
+        "responses": {
+          "200": {"description": "The tenant's API keys, leaving out any reaching beyond the caller's products", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/APIKeys"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/webhooks/create": {
+      "post": {
+        "summary": "Register a webhook",
+        "x-scope": "webhooks:admin",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"required": true, "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/WebhookRequest"}},
+          "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/WebhookRequest"}}
+        }},
+        "responses": {
+          "200": {"$ref": "#/components/responses/WebhookResponse"},
+          "201": {"$ref": "#/components/responses/WebhookResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/webhooks/delete": {
+      "post": {
+        "summary": "Delete a webhook",
+        "x-scope": "webhooks:admin",
+        "parameters": [{"$ref": "#/components/parameters/DryRun"}],
+        "requestBody": {"$ref": "#/components/requestBodies/IdRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/BasicResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/webhooks/all": {
+      "get": {
+        "summary": "List webhooks",
+        "x-scope": "webhooks:admin",
+        "responses": {
+          "200": {"description": "The tenant's webhooks", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhooks"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/webhooks/dead": {
+      "get": {
+        "summary": "List deliveries that ran out of attempts",
+        "x-scope": "webhooks:admin",
+        "responses": {
+          "200": {"description": "The dead deliveries", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/WebhookDeliveries"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v1/webhooks/replay": {
+      "post": {
+        "summary": "Queue a failed delivery again",
+        "description": "Deliveries that are dead or awaiting a retry can be replayed. Replaying one that was delivered gives 409.",
+        "x-scope": "webhooks:admin",
+        "requestBody": {"$ref": "#/components/requestBodies/IdRequest"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/BasicResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v2/licenses": {
+      "get": {
+        "summary": "List a product's licenses, valid or not",
+        "x-scope": "licenses:read",
+        "parameters": [
+          {"name": "product", "in": "query", "required": true, "schema": {"type": "string", "minLength": 1}}
+        ],
+        "responses": {
+          "200": {"description": "The licenses, with encrypted keys", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Licenses"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Create a license",
+        "x-scope": "licenses:write",
+        "parameters": [{"$ref": "#/components/parameters/NoDryRun"}],
+        "requestBody": {"required": true, "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/LicenseCreate"}}
+        }},
+        "responses": {
+          "201": {"$ref": "#/components/responses/License"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v2/licenses/{id}": {
+      "get": {
+        "summary": "Get a license",
+        "x-scope": "licenses:read",
+        "parameters": [
+          {"$ref": "#/components/parameters/LicenseId"},
+          {"name": "If-None-Match", "in": "header", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/License"},
+          "304": {"description": "The license still has the ETag given in If-None-Match"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Change a valid license",
+        "description": "Setting suspended also needs the licenses:invalidate scope.",
+        "x-scope": "licenses:write",
+        "parameters": [
+          {"$ref": "#/components/parameters/LicenseId"},
+          {"$ref": "#/components/parameters/IfMatch"},
+          {"$ref": "#/components/parameters/NoDryRun"}
+        ],
+        "requestBody": {"required": true, "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/LicensePatch"}}
+        }},
+        "responses": {
+          "200": {"$ref": "#/components/responses/License"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "412": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Invalidate a license",
+        "x-scope": "licenses:invalidate",
+        "parameters": [
+          {"$ref": "#/components/parameters/LicenseId"},
+          {"$ref": "#/components/parameters/IfMatch"},
+          {"$ref": "#/components/parameters/NoDryRun"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/License"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "412": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v2/licenses/{id}/history": {
+      "get": {
+        "summary": "List what has been done to a license",
+        "x-scope": "licenses:read",
+        "parameters": [{"$ref": "#/components/parameters/LicenseId"}],
+        "responses": {
+          "200": {"description": "The license's history, oldest first", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/History"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v2/licenses/{id}/activations": {
+      "get": {
+        "summary": "List the machines a license is activated on",
+        "x-scope": "licenses:read",
+        "parameters": [{"$ref": "#/components/parameters/LicenseId"}],
+        "responses": {
+          "200": {"description": "The activations, oldest first", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Activations"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Activate a license on a machine",
+        "description": "A machine matching one already activated keeps its activation and gets 200. Going over max_activations gives 409 activations_exceeded.",
+        "x-scope": "licenses:write",
+        "parameters": [
+          {"$ref": "#/components/parameters/LicenseId"},
+          {"$ref": "#/components/parameters/NoDryRun"}
+        ],
+        "requestBody": {"required": true, "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/ActivationRequest"}}
+        }},
+        "responses": {
+          "200": {"$ref": "#/components/responses/ActivationResponse"},
+          "201": {"$ref": "#/components/responses/ActivationResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/v2/licenses/{id}/activations/{activation}": {
+      "delete": {
+        "summary": "Deactivate a machine, freeing its seat",
+        "x-scope": "licenses:write",
+        "parameters": [
+          {"$ref": "#/components/parameters/LicenseId"},
+          {"name": "activation", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}},
+          {"$ref": "#/components/parameters/NoDryRun"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/BasicResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/license/check": {
+      "post": {
+        "summary": "Check a license",
+        "description": "Rate limited per client. When a nonce is sent the response is signed with the tenant's key, see /license/pubkey.",
+        "security": [],
+        "requestBody": {"required": true, "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/CheckRequest"}},
+          "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/CheckRequest"}}
+        }},
+        "responses": {
+          "200": {"$ref": "#/components/responses/LicenseResponse"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/LicenseResponse"},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    },
+    "/license/pubkey": {
+      "get": {
+        "summary": "Get the key check responses are signed with",
+        "security": [],
+        "responses": {
+          "200": {"description": "The public key", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PublicKey"}}}},
+          "429": {"$ref": "#/components/responses/RateLimited"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "basicAuth": {"type": "http", "scheme": "basic"},
+      "bearerAuth": {"type": "http", "scheme": "bearer"},
+      "apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"}
+    },
+    "parameters": {
+      "DryRun": {
+        "name": "dry_run",
+        "in": "query",
+        "description": "Validate the request without changing anything",
+        "schema": {"type": "boolean"}
+      },
+      "NoDryRun": {
+        "name": "dry_run",
+        "in": "query",
+        "description": "The v2 API doesn't do dry runs, so a request asking for one is refused with 400 rather than carried out",
+        "schema": {"type": "boolean"}
+      },
+      "LicenseId": {
+        "name": "id",
+        "in": "path",
+        "required": true,
+        "schema": {"type": "integer", "minimum": 1}
+      },
+      "IfMatch": {
+        "name": "If-Match",
+        "in": "header",
+        "description": "Only make the change if the license still has this ETag",
+        "schema": {"type": "string"}
+      }
+    },
+    "requestBodies": {
+      "BasicRequest": {"required": true, "content": {
+        "application/json": {"schema": {"$ref": "#/components/schemas/BasicRequest"}},
+        "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/BasicRequest"}}
+      }},
+      "IdRequest": {"required": true, "content": {
+        "application/json": {"schema": {"$ref": "#/components/schemas/IdRequest"}},
+        "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/IdRequest"}}
+      }},
+      "LicenseRequest": {"required": true, "content": {
+        "application/json": {"schema": {"$ref": "#/components/schemas/LicenseRequest"}},
+        "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/LicenseRequest"}}
+      }}
+    },
+    "responses": {
+      "Error": {"description": "The request failed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "RateLimited": {"description": "Too many requests", "headers": {"Retry-After": {"description": "Seconds until the next request is allowed", "schema": {"type": "integer"}}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "BasicResponse": {"description": "What happened", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BasicResponse"}}}},
+      "License": {"description": "The license, with an encrypted key", "headers": {"ETag": {"schema": {"type": "string"}}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/License"}}}},
+      "ActivationResponse": {"description": "The activation", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ActivationResponse"}}}},
+      "LicenseResponse": {"description": "What happened to the license", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LicenseResponse"}}}},
+      "APIKeyResponse": {"description": "The new API key", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/APIKeyResponse"}}}},
+      "WebhookResponse": {"description": "The new webhook", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/WebhookResponse"}}}}
+    },
+    "schemas": {
+      "Date": {
+        "type": "string",
+        "description": "A date as YYYY-MM-DD, or empty for none",
+        "pattern": "^([0-9]{4}-[0-9]{2}-[0-9]{2})?$"
+      },
+      "BasicRequest": {
+        "type": "object",
+        "required": ["key"],
+        "properties": {
+          "key": {"type": "string", "minLength": 1, "description": "An encrypted license key"}
+        }
+      },
+      "IdRequest": {
+        "type": "object",
+        "required": ["id"],
+        "properties": {
+          "id": {"type": "integer", "minimum": 1}
+        }
+      },
+      "CheckRequest": {
+        "type": "object",
+        "required": ["key", "product"],
+        "properties": {
+          "key": {"type": "string", "minLength": 1},
+          "product": {"type": "string", "minLength": 1},
+          "version": {"type": "string"},
+          "release_date": {"$ref": "#/components/schemas/Date"},
+          "nonce": {"type": "string", "maxLength": 128}
+        }
+      },
+      "LicenseRequest": {
+        "type": "object",
+        "required": ["email", "product"],
+        "properties": {
+          "email": {"type": "string", "minLength": 1, "maxLength": 100},
+          "product": {"type": "string", "minLength": 1, "maxLength": 250},
+          "max_version": {"type": "integer", "minimum": 0},
+          "updates_until": {"$ref": "#/components/schemas/Date"},
+          "expires_at": {"$ref": "#/components/schemas/Date"},
+          "entitlements": {"type": "array", "nullable": true, "items": {"type": "string", "minLength": 1, "pattern": "^[^,]*$"}}
+        }
+      },
+      "LicenseCreate": {
+        "type": "object",
+        "required": ["email", "product"],
+        "properties": {
+          "email": {"type": "string", "minLength": 1, "maxLength": 100},
+          "product": {"type": "string", "minLength": 1, "maxLength": 250},
+          "max_version": {"type": "integer", "minimum": 0},
+          "updates_until": {"$ref": "#/components/schemas/Date"},
+          "expires_at": {"$ref": "#/components/schemas/Date"},
+          "entitlements": {"type": "array", "nullable": true, "items": {"type": "string", "minLength": 1, "pattern": "^[^,]*$"}},
+          "max_activations": {"type": "integer", "minimum": 0, "description": "How many machines may be activated at once, or 0 for any number"}
+        }
+      },
+      "LicensePatch": {
+        "type": "object",
+        "description": "Fields left out or null are not changed",
+        "properties": {
+          "email": {"type": "string", "nullable": true, "minLength": 1, "maxLength": 100},
+          "suspended": {"type": "boolean", "nullable": true},
+          "max_version": {"type": "integer", "nullable": true, "minimum": 0},
+          "updates_until": {"type": "string", "nullable": true, "pattern": "^([0-9]{4}-[0-9]{2}-[0-9]{2})?$"},
+          "expires_at": {"type": "string", "nullable": true, "pattern": "^([0-9]{4}-[0-9]{2}-[0-9]{2})?$"},
+          "entitlements": {"type": "array", "nullable": true, "items": {"type": "string", "minLength": 1, "pattern": "^[^,]*$"}},
+          "max_activations": {"type": "integer", "nullable": true, "minimum": 0}
+        }
+      },
+      "ActivationRequest": {
+        "type": "object",
+        "required": ["fingerprint"],
+        "properties": {
+          "fingerprint": {"type": "string", "description": "A fingerprint from ala/fingerprint", "pattern": "^fp2(\\.([0-9a-f]{16}|-)){3}$"}
+        }
+      },
+      "APIKeyRequest": {
+        "type": "object",
+        "required": ["name", "scopes"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1, "maxLength": 100},
+          "scopes": {"type": "array", "items": {"type": "string", "enum": ["licenses:read", "licenses:write", "licenses:invalidate", "keys:admin", "webhooks:admin"]}},
+          "products": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "expires_at": {"$ref": "#/components/schemas/Date"}
+        }
+      },
+      "WebhookRequest": {
+        "type": "object",
+        "required": ["url"],
+        "properties": {
+          "url": {"type": "string", "minLength": 1, "format": "uri"},
+          "events": {"type": "array", "nullable": true, "items": {"type": "string", "enum": ["license.created", "license.invalidated", "license.suspended", "license.resumed", "license.expired", "license.activations_exceeded"]}}
+        }
+      },
+      "BasicResponse": {
+        "type": "object",
+        "properties": {
+          "status": {"type": "string"},
+          "message": {"type": "string"},
+          "code": {"type": "integer"}
+        }
+      },
+      "ErrorResponse": {
+        "type": "object",
+        "properties": {
+          "status": {"type": "string", "enum": ["error"]},
+          "error": {"type": "string", "enum": ["invalid_request", "invalid_key_format", "license_not_found", "product_mismatch", "unauthorized", "forbidden", "not_found", "conflict", "precondition_failed", "activations_exceeded", "rate_limited", "internal"]},
+          "message": {"type": "string"},
+          "code": {"type": "integer"}
+        }
+      },
+      "License": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "key": {"type": "string"},
+          "product": {"type": "string"},
+          "email": {"type": "string"},
+          "valid": {"type": "boolean"},
+          "suspended": {"type": "boolean"},
+          "max_version": {"type": "integer"},
+          "updates_until": {"type": "string", "format": "date-time"},
+          "expires_at": {"type": "string", "format": "date-time"},
+          "entitlements": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "max_activations": {"type": "integer"},
+          "code": {"type": "integer"}
+        }
+      },
+      "Licenses": {
+        "type": "object",
+        "properties": {
+          "code": {"type": "integer"},
+          "licenses": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/License"}}
+        }
+      },
+      "LicenseResponse": {
+        "type": "object",
+        "properties": {
+          "license_key": {"type": "string"},
+          "product": {"type": "string"},
+          "version": {"type": "string"},
+          "release_date": {"type": "string"},
+          "status": {"type": "string", "enum": ["created", "invalidated", "suspended", "resumed", "unchanged", "valid", "invalid", "expired", "version_not_covered", "dry_run"]},
+          "message": {"type": "string"},
+          "code": {"type": "integer"},
+          "error": {"type": "string", "enum": ["license_not_found", "product_mismatch"]},
+          "expires_at": {"type": "string", "format": "date-time"},
+          "entitlements": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "nonce": {"type": "string"},
+          "timestamp": {"type": "integer"},
+          "signature": {"type": "string", "description": "Base64 ed25519 signature, present when the request had a nonce"}
+        }
+      },
+      "Products": {
+        "type": "object",
+        "properties": {
+          "code": {"type": "integer"},
+          "products": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "Activation": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "fingerprint": {"type": "string"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "last_seen_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Activations": {
+        "type": "object",
+        "properties": {
+          "code": {"type": "integer"},
+          "max_activations": {"type": "integer"},
+          "activations": {"type": "array", "items": {"$ref": "#/components/schemas/Activation"}}
+        }
+      },
+      "ActivationResponse": {
+        "type": "object",
+        "properties": {
+          "activation": {"$ref": "#/components/schemas/Activation"},
+          "status": {"type": "string", "enum": ["activated", "unchanged"]},
+          "message": {"type": "string"},
+          "code": {"type": "integer"}
+        }
+      },
+      "HistoryEntry": {
+        "type": "object",
+        "properties": {
+          "action": {"type": "string"},
+          "actor": {"type": "string"},
+          "details": {"type": "string"},
+          "created_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "History": {
+        "type": "object",
+        "properties": {
+          "key": {"type": "string"},
+          "history": {"type": "array", "items": {"$ref": "#/components/schemas/HistoryEntry"}},
+          "code": {"type": "integer"}
+        }
+      },
+      "APIKey": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "tenant_id": {"type": "integer"},
+          "name": {"type": "string"},
+          "prefix": {"type": "string"},
+          "scopes": {"type": "array", "items": {"type": "string"}},
+          "products": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "expires_at": {"type": "string", "format": "date-time"},
+          "last_used_at": {"type": "string", "format": "date-time"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "revoked": {"type": "boolean"}
+        }
+      },
+      "APIKeys": {
+        "type": "object",
+        "properties": {
+          "code": {"type": "integer"},
+          "keys": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/APIKey"}}
+        }
+      },
+      "APIKeyResponse": {
+        "type": "object",
+        "properties": {
+          "key": {"type": "string", "description": "The secret, only ever shown here"},
+          "api_key": {"$ref": "#/components/schemas/APIKey"},
+          "status": {"type": "string"},
+          "message": {"type": "string"},
+          "code": {"type": "integer"}
+        }
+      },
+      "Webhook": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "url": {"type": "string"},
+          "secret": {"type": "string"},
+          "events": {"type": "array", "nullable": true, "items": {"type": "string"}},
+          "active": {"type": "boolean"},
+          "created_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Webhooks": {
+        "type": "object",
+        "properties": {
+          "code": {"type": "integer"},
+          "webhooks": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/Webhook"}}
+        }
+      },
+      "WebhookResponse": {
+        "type": "object",
+        "properties": {
+          "webhook": {"$ref": "#/components/schemas/Webhook"},
+          "status": {"type": "string"},
+          "message": {"type": "string"},
+          "code": {"type": "integer"}
+        }
+      },
+      "WebhookDelivery": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "webhook_id": {"type": "integer"},
+          "event": {"type": "string"},
+          "payload": {"type": "string"},
+          "status": {"type": "string"},
+          "attempts": {"type": "integer"},
+          "next_attempt_at": {"type": "string", "format": "date-time"},
+          "last_error": {"type": "string"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "delivered_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "WebhookDeliveries": {
+        "type": "object",
+        "properties": {
+          "code": {"type": "integer"},
+          "deliveries": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/WebhookDelivery"}}
+        }
+      },
+      "PublicKey": {
+        "type": "object",
+        "properties": {
+          "algorithm": {"type": "string", "enum": ["ed25519"]},
+          "public_key": {"type": "string"},
+          "code": {"type": "integer"}
+        }
+      }
+    }
+  }
+}`
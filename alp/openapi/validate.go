@@ -0,0 +1,320 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidationError says which part of a request broke the document's rules.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + " " + e.Reason
+}
+
+// ValidateRequest checks r's parameters and body against the operation the
+// document declares for route, a gin route like /api/v1/all/:product, whose
+// path parameters are params. Routes the document doesn't know pass. A JSON
+// body is put back for the handler to read again.
+func ValidateRequest(r *http.Request, route string, params map[string]string) error {
+	op, ok := operation(r.Method, Path(route))
+	if !ok {
+		return nil
+	}
+
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		param = param.resolve()
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = params[param.Name]
+		case "query":
+			_, present = query[param.Name]
+			value = query.Get(param.Name)
+		case "header":
+			value = r.Header.Get(param.Name)
+			present = value != ""
+		}
+		if !present {
+			if param.Required {
+				return &ValidationError{param.Name, "is required"}
+			}
+			continue
+		}
+		if err := validate(param.Schema, coerce(param.Schema, value), param.Name); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	return validateBody(r, op.RequestBody.resolve())
+}
+
+// ValidateResponse checks that the document lists status among the
+// responses to method on route, and that body fits the JSON schema it gives
+// for that status, if any.
+func ValidateResponse(method, route string, status int, body []byte) error {
+	op, ok := operation(method, Path(route))
+	if !ok {
+		return &ValidationError{Reason: fmt.Sprintf("%s %s is not documented", method, route)}
+	}
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return &ValidationError{Reason: fmt.Sprintf("status %d is not documented", status)}
+	}
+	content, ok := response.resolve().Content["application/json"]
+	if !ok || content.Schema == nil {
+		if len(bytes.TrimSpace(body)) > 0 && len(response.resolve().Content) == 0 {
+			return &ValidationError{Reason: fmt.Sprintf("status %d has no documented body", status)}
+		}
+		return nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Reason: "body is not valid JSON"}
+	}
+	return validate(content.Schema, value, "")
+}
+
+func validateBody(r *http.Request, body *RequestBody) error {
+	mediaType := "application/x-www-form-urlencoded"
+	if header := r.Header.Get("Content-Type"); header != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(header); err != nil {
+			return &ValidationError{Reason: "invalid content type"}
+		}
+	}
+	if mediaType == "multipart/form-data" {
+		mediaType = "application/x-www-form-urlencoded"
+	}
+	content, ok := body.Content[mediaType]
+	if !ok {
+		return &ValidationError{Reason: "unsupported content type " + mediaType}
+	}
+
+	var value interface{}
+	if mediaType == "application/json" {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return &ValidationError{Reason: "unreadable body"}
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		if len(bytes.TrimSpace(b)) == 0 {
+			if body.Required {
+				return &ValidationError{Reason: "request body is required"}
+			}
+			return nil
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		if err = decoder.Decode(&value); err != nil {
+			return &ValidationError{Reason: "body is not valid JSON"}
+		}
+	} else {
+		// Like gin's form binding, fields may come from the query too.
+		if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+			return &ValidationError{Reason: "unreadable form"}
+		}
+		value = formObject(content.Schema, r.Form)
+	}
+	return validate(content.Schema, value, "")
+}
+
+// formObject reads form values into the shape schema expects, so they can
+// be validated like a JSON body.
+func formObject(schema *Schema, form url.Values) map[string]interface{} {
+	object := map[string]interface{}{}
+	for name, property := range schema.resolve().Properties {
+		values, ok := form[name]
+		if !ok {
+			continue
+		}
+		if property.resolve().Type == "array" {
+			items := make([]interface{}, len(values))
+			for i, v := range values {
+				items[i] = coerce(property.resolve().Items, v)
+			}
+			object[name] = items
+		} else if len(values) > 0 {
+			object[name] = coerce(property, values[0])
+		}
+	}
+	return object
+}
+
+// coerce turns a string from a query or form into the type schema wants,
+// leaving it a string when it can't be, so validation reports it.
+func coerce(schema *Schema, value string) interface{} {
+	switch schema.resolve().Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func validate(schema *Schema, value interface{}, field string) error {
+	schema = schema.resolve()
+	if value == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return &ValidationError{field, "must not be null"}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return &ValidationError{field, "must be an object"}
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{join(field, name), "is required"}
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := object[name]; ok {
+				if err := validate(schema.Properties[name], v, join(field, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return &ValidationError{field, "must be an array"}
+		}
+		for i, item := range items {
+			if err := validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return &ValidationError{field, "must be a string"}
+		}
+		return validateString(schema, s, field)
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return &ValidationError{field, "must be an integer"}
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return &ValidationError{field, "must be an integer"}
+		}
+		if schema.Minimum != nil && float64(i) < *schema.Minimum {
+			return &ValidationError{field, fmt.Sprintf("must be at least %v", *schema.Minimum)}
+		}
+	case "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return &ValidationError{field, "must be a number"}
+		}
+		f, _ := n.Float64()
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return &ValidationError{field, fmt.Sprintf("must be at least %v", *schema.Minimum)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &ValidationError{field, "must be true or false"}
+		}
+	}
+	return nil
+}
+
+func validateString(schema *Schema, s, field string) error {
+	length := utf8.RuneCountInString(s)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		if *schema.MinLength == 1 {
+			return &ValidationError{field, "must not be empty"}
+		}
+		return &ValidationError{field, fmt.Sprintf("must be at least %d characters", *schema.MinLength)}
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		return &ValidationError{field, fmt.Sprintf("must be at most %d characters", *schema.MaxLength)}
+	}
+	if schema.Pattern != "" && !pattern(schema.Pattern).MatchString(s) {
+		if schema == doc.Components.Schemas["Date"] {
+			return &ValidationError{field, "must be a date like 2006-01-02"}
+		}
+		return &ValidationError{field, "must match " + schema.Pattern}
+	}
+	if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+		return &ValidationError{field, fmt.Sprintf("must be one of %v", schema.Enum)}
+	}
+	if schema.Format == "uri" {
+		if u, err := url.ParseRequestURI(s); err != nil || u.Host == "" {
+			return &ValidationError{field, "must be an absolute URL"}
+		}
+	}
+	return nil
+}
+
+var (
+	patternsMu sync.Mutex
+	patterns   = map[string]*regexp.Regexp{}
+)
+
+func pattern(expr string) *regexp.Regexp {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	re, ok := patterns[expr]
+	if !ok {
+		re = regexp.MustCompile(expr)
+		patterns[expr] = re
+	}
+	return re
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,6 @@
+package alp
+
+type Products struct {
+	Code     int      `json:"code"`
+	Products []string `json:"products"`
+}
@@ -0,0 +1,56 @@
+package alp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LicenseResponse struct {
+	LicenseKey string `json:"license_key"`
+	Product    string `json:"product,omitempty"`
+	// Version and ReleaseDate echo the check request, as the status may
+	// depend on them.
+	Version     string `json:"version,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Code        int    `json:"code"`
+	// Error is set on check responses that aren't valid for a reason
+	// clients may want to tell apart, like ErrorProductMismatch. It is not
+	// covered by the signature.
+	Error        string     `json:"error,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Entitlements []string   `json:"entitlements,omitempty"`
+	Nonce        string     `json:"nonce,omitempty"`
+	Timestamp    int64      `json:"timestamp,omitempty"`
+	Signature    string     `json:"signature,omitempty"`
+}
+
+// SigningPayload is the message a check response's signature covers. Its
+// first line names the layout and changes whenever the layout does, so a
+// signature never verifies against a payload it wasn't made over:
+// als-check-v2 added the expiry, als-check-v3 the entitlements and
+// als-check-v4 the version and release date.
+func (r LicenseResponse) SigningPayload() []byte {
+	var expiry int64
+	if r.ExpiresAt != nil {
+		expiry = r.ExpiresAt.Unix()
+	}
+	return []byte("als-check-v4\n" +
+		r.LicenseKey + "\n" +
+		r.Product + "\n" +
+		r.Version + "\n" +
+		r.ReleaseDate + "\n" +
+		r.Status + "\n" +
+		r.Nonce + "\n" +
+		strconv.FormatInt(r.Timestamp, 10) + "\n" +
+		strconv.FormatInt(expiry, 10) + "\n" +
+		strings.Join(r.Entitlements, ","))
+}
+
+type BasicResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
@@ -0,0 +1,30 @@
+package alp
+
+// Statuses a response can carry.
+const (
+	StatusCreated           = "created"
+	StatusInvalidated       = "invalidated"
+	StatusSuspended         = "suspended"
+	StatusResumed           = "resumed"
+	StatusUnchanged         = "unchanged"
+	StatusRevoked           = "revoked"
+	StatusDeleted           = "deleted"
+	StatusActivated         = "activated"
+	StatusValid             = "valid"
+	StatusInvalid           = "invalid"
+	StatusExpired           = "expired"
+	StatusVersionNotCovered = "version_not_covered"
+	StatusDryRun            = "dry_run"
+	StatusError             = "error"
+)
+
+// Messages the server sends for outcomes clients act on. Clients compare
+// against these, so changing one is a protocol change.
+const (
+	MessageLicenseNonexistent    = "license nonexistent"
+	MessageIncorrectProduct      = "incorrect product"
+	MessageLicenseAlreadyInvalid = "license already invalid"
+	MessageAPIKeyNonexistent     = "api key nonexistent"
+	MessageAPIKeyAlreadyRevoked  = "api key already revoked"
+	MessageRequiredParameters    = "required parameters not provided"
+)
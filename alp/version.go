@@ -0,0 +1,57 @@
+package alp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout is how dates without a time are written, like release_date
+// and updates_until.
+const DateLayout = "2006-01-02"
+
+func ParseMajorVersion(version string) (int, error) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	major := strings.SplitN(version, ".", 2)[0]
+	n, err := strconv.Atoi(major)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid version")
+	}
+	return n, nil
+}
+
+// ParseDate parses a DateLayout date. An empty date is nil.
+func ParseDate(date string) (*time.Time, error) {
+	if date == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return nil, errors.New("invalid date")
+	}
+	return &t, nil
+}
+
+// VersionCovered reports whether a client running version, released on
+// releaseDate, may use the license. Empty arguments are not checked.
+func VersionCovered(lic License, version, releaseDate string) (bool, error) {
+	if version != "" && lic.MaxVersion > 0 {
+		major, err := ParseMajorVersion(version)
+		if err != nil {
+			return false, err
+		}
+		if major > lic.MaxVersion {
+			return false, nil
+		}
+	}
+
+	released, err := ParseDate(releaseDate)
+	if err != nil {
+		return false, err
+	}
+	if released != nil && lic.UpdatesUntil != nil && released.After(*lic.UpdatesUntil) {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,41 @@
+package alp
+
+import "time"
+
+type Webhook struct {
+	Id        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Webhooks struct {
+	Code     int       `json:"code"`
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url" form:"url" binding:"required"`
+	Events []string `json:"events" form:"events"`
+}
+
+type WebhookDelivery struct {
+	Id          int        `json:"id"`
+	TenantId    int        `json:"-"`
+	WebhookId   int        `json:"webhook_id"`
+	Event       string     `json:"event"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	NextAttempt time.Time  `json:"next_attempt_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveries struct {
+	Code       int               `json:"code"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
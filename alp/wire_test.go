@@ -0,0 +1,255 @@
+package alp
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var (
+	testCreated = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	testExpires = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	testUpdates = time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// roundTrip marshals in, checks the JSON has exactly keys, unmarshals it
+// into a new value of the same type and compares that with in field by
+// field.
+func roundTrip(t *testing.T, in interface{}, keys ...string) {
+	t.Helper()
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for k := range fields {
+		got = append(got, k)
+	}
+	sort.Strings(got)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(got, keys) {
+		t.Errorf("%T keys = %v, want %v", in, got, keys)
+	}
+
+	out := reflect.New(reflect.TypeOf(in))
+	if err = json.Unmarshal(b, out.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	compareFields(t, reflect.TypeOf(in).Name(), reflect.ValueOf(in), out.Elem())
+}
+
+func compareFields(t *testing.T, path string, want, got reflect.Value) {
+	t.Helper()
+	if want.Kind() == reflect.Struct && want.Type() != reflect.TypeOf(time.Time{}) {
+		for i := 0; i < want.NumField(); i++ {
+			compareFields(t, path+"."+want.Type().Field(i).Name, want.Field(i), got.Field(i))
+		}
+		return
+	}
+	if !reflect.DeepEqual(want.Interface(), got.Interface()) {
+		t.Errorf("%s = %v, want %v", path, got.Interface(), want.Interface())
+	}
+}
+
+func TestLicenseRoundTrip(t *testing.T) {
+	roundTrip(t, License{
+		Id:             7,
+		LicenseKey:     "bGljZW5zZS1rZXk=",
+		Product:        "prod",
+		Email:          "a@example.com",
+		Valid:          true,
+		Suspended:      true,
+		MaxVersion:     3,
+		UpdatesUntil:   &testUpdates,
+		ExpiresAt:      &testExpires,
+		Entitlements:   []string{"export", "sso"},
+		MaxActivations: 2,
+		Code:           200,
+	}, "id", "key", "product", "email", "valid", "suspended", "max_version", "updates_until",
+		"expires_at", "entitlements", "max_activations", "code")
+
+	roundTrip(t, Licenses{Code: 200, Licenses: []License{{Id: 1, LicenseKey: "a", Product: "prod", Valid: true, Code: 200}}},
+		"code", "licenses")
+}
+
+func TestLicenseRevisionNotSent(t *testing.T) {
+	b, err := json.Marshal(License{Revision: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lic License
+	if err = json.Unmarshal(b, &lic); err != nil {
+		t.Fatal(err)
+	}
+	if lic.Revision != 0 {
+		t.Errorf("Revision went over the wire: %s", b)
+	}
+}
+
+func TestLicenseRequestRoundTrip(t *testing.T) {
+	roundTrip(t, LicenseRequest{
+		Email:        "a@example.com",
+		Product:      "prod",
+		MaxVersion:   2,
+		UpdatesUntil: "2024-12-31",
+		ExpiresAt:    "2025-03-01",
+		Entitlements: []string{"export"},
+	}, "email", "product", "max_version", "updates_until", "expires_at", "entitlements")
+}
+
+func TestCheckRequestRoundTrip(t *testing.T) {
+	roundTrip(t, CheckRequest{
+		Key:         "bGljZW5zZS1rZXk=",
+		Product:     "prod",
+		Version:     "2.1.0",
+		ReleaseDate: "2024-06-01",
+		Nonce:       "bm9uY2U",
+	}, "key", "product", "version", "release_date", "nonce")
+}
+
+func testLicenseResponse() LicenseResponse {
+	return LicenseResponse{
+		LicenseKey:   "bGljZW5zZS1rZXk=",
+		Product:      "prod",
+		Version:      "2.1.0",
+		ReleaseDate:  "2024-06-01",
+		Status:       StatusValid,
+		Message:      "license valid",
+		Code:         200,
+		Error:        ErrorProductMismatch,
+		ExpiresAt:    &testExpires,
+		Entitlements: []string{"export", "sso"},
+		Nonce:        "bm9uY2U",
+		Timestamp:    1709296200,
+		Signature:    "c2lnbmF0dXJl",
+	}
+}
+
+func TestLicenseResponseRoundTrip(t *testing.T) {
+	roundTrip(t, testLicenseResponse(), "license_key", "product", "version", "release_date", "status",
+		"message", "code", "error", "expires_at", "entitlements", "nonce", "timestamp", "signature")
+}
+
+func TestSigningPayload(t *testing.T) {
+	resp := testLicenseResponse()
+	want := "als-check-v4\n" +
+		"bGljZW5zZS1rZXk=\n" +
+		"prod\n" +
+		"2.1.0\n" +
+		"2024-06-01\n" +
+		"valid\n" +
+		"bm9uY2U\n" +
+		"1709296200\n" +
+		"1740787200\n" +
+		"export,sso"
+	if got := string(resp.SigningPayload()); got != want {
+		t.Fatalf("payload changed without a new domain tag:\n%q\nwant\n%q", got, want)
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded LicenseResponse
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded.SigningPayload()) != want {
+		t.Errorf("payload differs after a round trip: %q", decoded.SigningPayload())
+	}
+
+	// Fields outside the signature must not change it.
+	resp.Message, resp.Code, resp.Error, resp.Signature = "other", 404, "", ""
+	if string(resp.SigningPayload()) != want {
+		t.Errorf("unsigned fields changed the payload: %q", resp.SigningPayload())
+	}
+
+	resp = testLicenseResponse()
+	resp.ExpiresAt, resp.Entitlements = nil, nil
+	if got := string(resp.SigningPayload()); got[len(got)-3:] != "\n0\n" {
+		t.Errorf("payload without expiry or entitlements = %q", got)
+	}
+}
+
+func TestErrorResponseRoundTrip(t *testing.T) {
+	roundTrip(t, ErrorResponse{
+		Status:  StatusError,
+		Error:   ErrorLicenseNotFound,
+		Message: MessageLicenseNonexistent,
+		Code:    404,
+	}, "status", "error", "message", "code")
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	roundTrip(t, History{
+		LicenseKey: "bGljZW5zZS1rZXk=",
+		History: []HistoryEntry{
+			{Action: "created", Actor: "admin", CreatedAt: testCreated},
+			{Action: "suspended", Actor: "key:ci", Details: "chargeback", CreatedAt: testCreated.Add(time.Hour)},
+		},
+		Code: 200,
+	}, "key", "history", "code")
+
+	roundTrip(t, HistoryEntry{Action: "suspended", Actor: "key:ci", Details: "chargeback", CreatedAt: testCreated},
+		"action", "actor", "details", "created_at")
+}
+
+func TestAPIKeyRoundTrip(t *testing.T) {
+	lastUsed := testCreated.Add(48 * time.Hour)
+	key := APIKey{
+		Id:         3,
+		TenantId:   1,
+		Name:       "ci",
+		Prefix:     "alsk_abcd",
+		Scopes:     []string{"licenses:read", "licenses:write"},
+		Products:   []string{"prod"},
+		ExpiresAt:  &testExpires,
+		LastUsedAt: &lastUsed,
+		CreatedAt:  testCreated,
+		Revoked:    true,
+	}
+	roundTrip(t, key, "id", "tenant_id", "name", "prefix", "scopes", "products", "expires_at",
+		"last_used_at", "created_at", "revoked")
+
+	roundTrip(t, APIKeyResponse{
+		Key:     "alsk_abcd_secret",
+		APIKey:  key,
+		Status:  StatusCreated,
+		Message: "api key created",
+		Code:    201,
+	}, "key", "api_key", "status", "message", "code")
+
+	roundTrip(t, APIKeyRequest{
+		Name:      "ci",
+		Scopes:    []string{"licenses:read"},
+		Products:  []string{"prod"},
+		ExpiresAt: "2025-03-01",
+	}, "name", "scopes", "products", "expires_at")
+}
+
+func TestActivationRoundTrip(t *testing.T) {
+	activation := Activation{
+		Id:          9,
+		Fingerprint: "fp2.0123456789abcdef.-.fedcba9876543210",
+		CreatedAt:   testCreated,
+		LastSeenAt:  testCreated.Add(time.Minute),
+	}
+	roundTrip(t, activation, "id", "fingerprint", "created_at", "last_seen_at")
+
+	roundTrip(t, Activations{Code: 200, MaxActivations: 2, Activations: []Activation{activation}},
+		"code", "max_activations", "activations")
+
+	roundTrip(t, ActivationResponse{
+		Activation: activation,
+		Status:     StatusActivated,
+		Message:    "machine activated",
+		Code:       201,
+	}, "activation", "status", "message", "code")
+}
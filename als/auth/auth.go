@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	ScopeLicensesRead       = "licenses:read"
+	ScopeLicensesWrite      = "licenses:write"
+	ScopeLicensesInvalidate = "licenses:invalidate"
+	ScopeKeysAdmin          = "keys:admin"
+	ScopeWebhooksAdmin      = "webhooks:admin"
+
+	keyPrefix = "als_"
+)
+
+var AllScopes = []string{
+	ScopeLicensesRead,
+	ScopeLicensesWrite,
+	ScopeLicensesInvalidate,
+	ScopeKeysAdmin,
+	ScopeWebhooksAdmin,
+}
+
+// Principal is whoever is making an authenticated request, either an admin
+// user or an API key.
+type Principal struct {
+	Name     string
+	KeyId    int
+	Scopes   []string
+	Products []string
+}
+
+// Actor identifies the principal in license history.
+func (p Principal) Actor() string {
+	if p.KeyId != 0 {
+		return "key:" + p.Name
+	}
+	return "user:" + p.Name
+}
+
+func (p Principal) Can(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanProduct reports whether the principal may act on product. A principal
+// without product restrictions may act on every product.
+func (p Principal) CanProduct(product string) bool {
+	if len(p.Products) == 0 {
+		return true
+	}
+	for _, prod := range p.Products {
+		if prod == product {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateAPIKey returns a new plaintext key, the prefix used to identify it
+// in listings, and the hash that gets stored.
+func GenerateAPIKey() (string, string, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(keyPrefix)+8], HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+const (
+	RoleViewer  = "viewer"
+	RoleSupport = "support"
+	RoleIssuer  = "issuer"
+	RoleOwner   = "owner"
+)
+
+var Roles = []string{RoleViewer, RoleSupport, RoleIssuer, RoleOwner}
+
+var roleScopes = map[string][]string{
+	RoleViewer:  {ScopeLicensesRead},
+	RoleSupport: {ScopeLicensesRead, ScopeLicensesInvalidate},
+	RoleIssuer:  {ScopeLicensesRead, ScopeLicensesWrite, ScopeLicensesInvalidate},
+	RoleOwner:   AllScopes,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+func RoleScopes(role string) []string {
+	return roleScopes[role]
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// noUserHash is a bcrypt hash at DefaultCost that no password is expected
+// to match.
+const noUserHash = "$2a$10$G3YxXXS6j3mVJk1a5Bk.H.yz0kz9YUKAAm.1drA9WGYuIFwGCaMi."
+
+// CheckPassword reports whether password matches hash. An empty hash, for a
+// user that doesn't exist, never matches but takes as long to check, so
+// timing doesn't give away which usernames exist.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword([]byte(noUserHash), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "hunter2") {
+		t.Error("correct password rejected")
+	}
+	if CheckPassword(hash, "hunter3") {
+		t.Error("wrong password accepted")
+	}
+	if CheckPassword("", "") || CheckPassword("", "hunter2") {
+		t.Error("password accepted for a missing user")
+	}
+}
+
+func TestNoUserHashCost(t *testing.T) {
+	// A cheaper hash would make missing users answer faster.
+	cost, err := bcrypt.Cost([]byte(noUserHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("noUserHash has cost %d, passwords are hashed at %d", cost, bcrypt.DefaultCost)
+	}
+}
@@ -0,0 +1,43 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+)
+
+// GenerateSigningKey returns a new base64 encoded Ed25519 seed.
+func GenerateSigningKey() (string, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return EncodeBase64(seed), nil
+}
+
+func ParseSigningKey(key string) (ed25519.PrivateKey, error) {
+	seed, err := DecodeBase64(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid signing key")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func Sign(key string, message []byte) (string, error) {
+	priv, err := ParseSigningKey(key)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase64(ed25519.Sign(priv, message)), nil
+}
+
+func PublicKey(key string) (string, error) {
+	priv, err := ParseSigningKey(key)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase64(priv.Public().(ed25519.PublicKey)), nil
+}
@@ -1,6 +1,100 @@
+-- Schema for a new database. Existing databases are brought up to date
+-- with the files in migrations/, see README.md.
+
+CREATE TABLE tenants (
+    id int not null unique auto_increment,
+    slug varchar(50) not null unique,
+    name varchar(250) not null,
+    host varchar(250) null unique,
+    crypt_key varchar(32) not null,
+    sign_key varchar(64) not null default '',
+    rate_limit int not null default 10,
+    created_at datetime not null default current_timestamp
+);
+
 CREATE TABLE licenses (
     id int not null unique auto_increment,
-    license_key varchar(18) not null unique,
+    tenant_id int not null default 1,
+    license_key varchar(18) not null,
     product varchar(250) not null,
-    email varchar(100) not null
-);
\ No newline at end of file
+    email varchar(100) not null,
+    valid boolean not null default true,
+    suspended boolean not null default false,
+    max_version int null,
+    updates_until datetime null,
+    expires_at datetime null,
+    entitlements varchar(1000) not null default '',
+    expiry_notified boolean not null default false,
+    max_activations int not null default 0,
+    revision int not null default 0,
+    unique (tenant_id, license_key)
+);
+
+CREATE TABLE activations (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    license_id int not null,
+    fingerprint varchar(55) not null,
+    created_at datetime not null default current_timestamp,
+    last_seen_at datetime not null default current_timestamp,
+    unique (tenant_id, license_id, fingerprint)
+);
+
+CREATE TABLE api_keys (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    name varchar(100) not null,
+    prefix varchar(12) not null,
+    key_hash char(64) not null unique,
+    scopes varchar(250) not null,
+    products varchar(1000) not null default '',
+    expires_at datetime null,
+    last_used_at datetime null,
+    created_at datetime not null default current_timestamp,
+    revoked boolean not null default false
+);
+
+CREATE TABLE users (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    username varchar(100) not null,
+    password_hash varchar(100) not null,
+    role varchar(20) not null,
+    disabled boolean not null default false,
+    created_at datetime not null default current_timestamp,
+    unique (tenant_id, username)
+);
+
+CREATE TABLE license_history (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    license_key varchar(18) not null,
+    action varchar(50) not null,
+    actor varchar(150) not null,
+    details varchar(1000) not null default '',
+    created_at datetime not null default current_timestamp
+);
+
+CREATE TABLE webhooks (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    url varchar(1000) not null,
+    secret varchar(64) not null,
+    events varchar(1000) not null default '',
+    active boolean not null default true,
+    created_at datetime not null default current_timestamp
+);
+
+CREATE TABLE webhook_deliveries (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    webhook_id int not null,
+    event varchar(50) not null,
+    payload text not null,
+    status varchar(20) not null default 'pending',
+    attempts int not null default 0,
+    next_attempt_at datetime not null default current_timestamp,
+    last_error varchar(1000) not null default '',
+    created_at datetime not null default current_timestamp,
+    delivered_at datetime null
+);
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/GreatGodApollo/alp"
+)
+
+const activationColumns = "id, fingerprint, created_at, last_seen_at"
+
+// GetActivations returns the machines a license is activated on, oldest
+// first.
+func GetActivations(db *sql.DB, tenantID, licenseID int) ([]alp.Activation, error) {
+	rows, err := db.Query("select "+activationColumns+" from activations where tenant_id = ? and license_id = ? order by id", tenantID, licenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activations := []alp.Activation{}
+	for rows.Next() {
+		var a alp.Activation
+		if err := rows.Scan(&a.Id, &a.Fingerprint, &a.CreatedAt, &a.LastSeenAt); err != nil {
+			return nil, err
+		}
+		activations = append(activations, a)
+	}
+	return activations, rows.Err()
+}
+
+// InsertActivation activates lic on a machine, unless that would go over
+// its activation limit. The license row is locked while the activations are
+// counted, so concurrent activations can't both take the last seat.
+func InsertActivation(db *sql.DB, tenantID int, lic alp.License, fingerprint, actor string) (alp.Activation, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return alp.Activation{}, err
+	}
+	defer tx.Rollback()
+
+	var max, count int
+	err = tx.QueryRow("select max_activations from licenses where tenant_id = ? and id = ? for update", tenantID, lic.Id).Scan(&max)
+	if err == sql.ErrNoRows {
+		return alp.Activation{}, ErrLicenseNonexistent
+	} else if err != nil {
+		return alp.Activation{}, err
+	}
+	err = tx.QueryRow("select count(*) from activations where tenant_id = ? and license_id = ?", tenantID, lic.Id).Scan(&count)
+	if err != nil {
+		return alp.Activation{}, err
+	}
+	if max > 0 && count >= max {
+		return alp.Activation{}, ErrActivationsExceeded
+	}
+
+	res, err := tx.Exec("insert activations SET tenant_id=?, license_id=?, fingerprint=?", tenantID, lic.Id, fingerprint)
+	if err != nil {
+		return alp.Activation{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return alp.Activation{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return alp.Activation{}, err
+	}
+	if err = RecordHistory(db, tenantID, lic.LicenseKey, HistoryActivated, actor, fingerprint); err != nil {
+		return alp.Activation{}, err
+	}
+
+	var a alp.Activation
+	err = db.QueryRow("select "+activationColumns+" from activations where tenant_id = ? and id = ?", tenantID, id).
+		Scan(&a.Id, &a.Fingerprint, &a.CreatedAt, &a.LastSeenAt)
+	return a, err
+}
+
+// TouchActivation marks an activation as seen now.
+func TouchActivation(db *sql.DB, tenantID, id int) error {
+	_, err := db.Exec("update activations set last_seen_at = current_timestamp where tenant_id = ? and id = ?", tenantID, id)
+	return err
+}
+
+// DeleteActivation frees the seat taken by an activation of lic.
+func DeleteActivation(db *sql.DB, tenantID int, lic alp.License, id int, actor string) error {
+	var fingerprint string
+	err := db.QueryRow("select fingerprint from activations where tenant_id = ? and license_id = ? and id = ?", tenantID, lic.Id, id).Scan(&fingerprint)
+	if err == sql.ErrNoRows {
+		return ErrActivationNonexistent
+	} else if err != nil {
+		return err
+	}
+
+	if _, err = db.Exec("delete from activations where tenant_id = ? and id = ?", tenantID, id); err != nil {
+		return err
+	}
+	return RecordHistory(db, tenantID, lic.LicenseKey, HistoryDeactivated, actor, fingerprint)
+}
@@ -2,8 +2,8 @@ package database
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
+	"github.com/GreatGodApollo/alp"
 	"github.com/GreatGodApollo/als/crypto"
 	"github.com/GreatGodApollo/als/models"
 	_ "github.com/jinzhu/gorm/dialects/mssql"
@@ -11,14 +11,22 @@ import (
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/spf13/viper"
+	"strings"
+	"time"
 )
 
+const licenseColumns = "id, license_key, product, email, valid, suspended, max_version, updates_until, expires_at, entitlements, max_activations, revision"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
 func Setup() (*sql.DB, error) {
 	return sql.Open("mysql", getConnectionString())
 }
 
 func getConnectionString() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8",
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=true",
 		viper.GetString("db.username"),
 		viper.GetString("db.password"),
 		viper.GetString("db.host"),
@@ -26,10 +34,10 @@ func getConnectionString() string {
 		viper.GetString("db.name"))
 }
 
-func CheckLicenseExist(db *sql.DB, key string) (bool, error) {
+func CheckLicenseExist(db *sql.DB, tenantID int, key string) (bool, error) {
 
 	var scanned string
-	err := db.QueryRow("select license_key from licenses where license_key = ?", key).Scan(&scanned)
+	err := db.QueryRow("select license_key from licenses where tenant_id = ? and license_key = ?", tenantID, key).Scan(&scanned)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -40,9 +48,9 @@ func CheckLicenseExist(db *sql.DB, key string) (bool, error) {
 	return true, nil
 }
 
-func CheckLicenseValid(db *sql.DB, key string) (bool, bool, error) {
+func CheckLicenseValid(db *sql.DB, tenantID int, key string) (bool, bool, error) {
 
-	exist, err := CheckLicenseExist(db, key)
+	exist, err := CheckLicenseExist(db, tenantID, key)
 	if err != nil {
 		return false, false, err
 	}
@@ -51,7 +59,7 @@ func CheckLicenseValid(db *sql.DB, key string) (bool, bool, error) {
 	}
 
 	var valid bool
-	err = db.QueryRow("select valid from licenses where license_key=?", key).Scan(&valid)
+	err = db.QueryRow("select valid from licenses where tenant_id=? and license_key=?", tenantID, key).Scan(&valid)
 	if err != nil {
 		return false, false, err
 	} else {
@@ -59,9 +67,9 @@ func CheckLicenseValid(db *sql.DB, key string) (bool, bool, error) {
 	}
 }
 
-func CheckLicenseValidProduct(db *sql.DB, key, product string) (bool, bool, error) {
+func CheckLicenseValidProduct(db *sql.DB, tenantID int, key, product string) (bool, bool, error) {
 
-	exist, err := CheckLicenseExist(db, key)
+	exist, err := CheckLicenseExist(db, tenantID, key)
 	if err != nil {
 		return false, false, err
 	}
@@ -71,7 +79,7 @@ func CheckLicenseValidProduct(db *sql.DB, key, product string) (bool, bool, erro
 
 	var valid bool
 	var prodScanned string
-	err = db.QueryRow("select valid, product from licenses where license_key=?", key).Scan(&valid, &prodScanned)
+	err = db.QueryRow("select valid, product from licenses where tenant_id=? and license_key=?", tenantID, key).Scan(&valid, &prodScanned)
 	if !valid {
 		return exist, valid, nil
 	}
@@ -82,90 +90,274 @@ func CheckLicenseValidProduct(db *sql.DB, key, product string) (bool, bool, erro
 		if product == prodScanned && valid {
 			return exist, valid, nil
 		} else if valid {
-			return exist, valid, errors.New("incorrect product")
+			return exist, valid, ErrIncorrectProduct
 		} else {
 			return exist, false, nil
 		}
 	}
 }
 
-func InvalidateLicense(db *sql.DB, key string) (bool, error) {
-	exist, valid, err := CheckLicenseValid(db, key)
+func InvalidateLicense(db *sql.DB, tenantID int, key, actor string) (bool, error) {
+	exist, valid, err := CheckLicenseValid(db, tenantID, key)
 	if err != nil {
 		return false, err
 	}
 
 	if exist && valid {
-		query, err := db.Prepare("update licenses set valid=? where license_key=?")
+		query, err := db.Prepare("update licenses set valid=?, revision=revision+1 where tenant_id=? and license_key=?")
 		if err != nil {
 			return false, err
 		}
-		_, err = query.Exec(false, key)
+		_, err = query.Exec(false, tenantID, key)
 		defer query.Close()
 		if err != nil {
 			return false, err
-		} else {
-			return true, nil
 		}
+		if err = RecordHistory(db, tenantID, key, HistoryInvalidated, actor, ""); err != nil {
+			return false, err
+		}
+		return true, nil
 	} else if exist {
-		return false, errors.New("license already invalid")
+		return false, ErrLicenseAlreadyInvalid
 	} else {
-		return false, errors.New("license nonexistent")
+		return false, ErrLicenseNonexistent
 	}
 }
 
-func GetWholeRecord(db *sql.DB, key string) (models.License, error) {
-	exist, err := CheckLicenseExist(db, key)
+// SuspendLicense sets whether a valid license is suspended. A suspended
+// license stays on record but fails checks until it is resumed.
+func SuspendLicense(db *sql.DB, tenantID int, key, actor string, suspended bool) error {
+	licObj, err := GetWholeRecord(db, tenantID, key)
 	if err != nil {
-		return models.License{}, err
+		return err
+	}
+	if !licObj.Valid {
+		return ErrLicenseAlreadyInvalid
+	}
+	if licObj.Suspended == suspended {
+		if suspended {
+			return ErrLicenseAlreadySuspended
+		}
+		return ErrLicenseNotSuspended
 	}
-	if exist {
-		var licObj models.License
 
-		err = db.QueryRow("select * from licenses where license_key = ?", key).Scan(&licObj.Id,
-			&licObj.LicenseKey,
-			&licObj.Product,
-			&licObj.Email,
-			&licObj.Valid)
+	_, err = db.Exec("update licenses set suspended=?, revision=revision+1 where tenant_id=? and license_key=?", suspended, tenantID, key)
+	if err != nil {
+		return err
+	}
+	action := HistoryResumed
+	if suspended {
+		action = HistorySuspended
+	}
+	return RecordHistory(db, tenantID, key, action, actor, "")
+}
+
+func GetWholeRecord(db *sql.DB, tenantID int, key string) (alp.License, error) {
+	exist, err := CheckLicenseExist(db, tenantID, key)
+	if err != nil {
+		return alp.License{}, err
+	}
+	if exist {
+		licObj, err := scanLicense(db.QueryRow("select "+licenseColumns+" from licenses where tenant_id = ? and license_key = ?", tenantID, key))
 		if err != nil {
-			return models.License{}, err
+			return alp.License{}, err
 		}
 		return licObj, nil
 	} else {
-		return models.License{}, errors.New("license nonexistent")
+		return alp.License{}, ErrLicenseNonexistent
 	}
 }
 
-func GetAllValidRecords(db *sql.DB, product string) (models.Licenses, error) {
-	rows, err := db.Query("select * from licenses where valid = 1 and product = ?", product)
+// GetLicenseByID looks a license up by its id rather than its key.
+func GetLicenseByID(db *sql.DB, tenantID, id int) (alp.License, error) {
+	licObj, err := scanLicense(db.QueryRow("select "+licenseColumns+" from licenses where tenant_id = ? and id = ?", tenantID, id))
+	if err == sql.ErrNoRows {
+		return alp.License{}, ErrLicenseNonexistent
+	}
+	return licObj, err
+}
+
+// UpdateLicense writes every field of lic that may change after creation,
+// provided lic.Revision is still current, and records action in its
+// history. It returns ErrLicenseModified when someone else changed the
+// license first.
+func UpdateLicense(db *sql.DB, tenantID int, lic alp.License, action, actor, details string) error {
+	var maxVersion sql.NullInt64
+	if lic.MaxVersion > 0 {
+		maxVersion = sql.NullInt64{Int64: int64(lic.MaxVersion), Valid: true}
+	}
+	var updatesUntil sql.NullTime
+	if lic.UpdatesUntil != nil {
+		updatesUntil = sql.NullTime{Time: *lic.UpdatesUntil, Valid: true}
+	}
+	var expiresAt sql.NullTime
+	if lic.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *lic.ExpiresAt, Valid: true}
+	}
+
+	res, err := db.Exec("update licenses set email=?, valid=?, suspended=?, max_version=?, updates_until=?, expires_at=?, entitlements=?, max_activations=?, revision=revision+1 where tenant_id=? and id=? and revision=?",
+		lic.Email, lic.Valid, lic.Suspended, maxVersion, updatesUntil, expiresAt, strings.Join(lic.Entitlements, ","), lic.MaxActivations,
+		tenantID, lic.Id, lic.Revision)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLicenseModified
+	}
+	return RecordHistory(db, tenantID, lic.LicenseKey, action, actor, details)
+}
+
+func GetAllValidRecords(db *sql.DB, tenant models.Tenant, product string) (alp.Licenses, error) {
+	return getRecords(db, tenant, "select "+licenseColumns+" from licenses where tenant_id = ? and valid = 1 and product = ?", tenant.Id, product)
+}
+
+// GetAllRecords is GetAllValidRecords including invalid licenses.
+func GetAllRecords(db *sql.DB, tenant models.Tenant, product string) (alp.Licenses, error) {
+	return getRecords(db, tenant, "select "+licenseColumns+" from licenses where tenant_id = ? and product = ? order by id", tenant.Id, product)
+}
+
+func getRecords(db *sql.DB, tenant models.Tenant, query string, args ...interface{}) (alp.Licenses, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return models.Licenses{}, err
+		return alp.Licenses{}, err
 	}
+	defer rows.Close()
 
-	got := models.Licenses{}.Licenses
+	got := alp.Licenses{}.Licenses
 	for rows.Next() {
-		var r models.License
-		err = rows.Scan(&r.Id,
-			&r.LicenseKey,
-			&r.Product,
-			&r.Email,
-			&r.Valid)
+		r, err := scanLicense(rows)
 		if err != nil {
-			return models.Licenses{}, err
+			return alp.Licenses{}, err
 		}
 
-		encr, err := crypto.Encrypt([]byte(viper.GetString("crypt.key")), []byte(r.LicenseKey))
+		encr, err := crypto.Encrypt([]byte(tenant.CryptKey), []byte(r.LicenseKey))
 		if err != nil {
-			return models.Licenses{}, err
+			return alp.Licenses{}, err
 		}
 		r.LicenseKey = crypto.EncodeBase64(encr)
 
 		got = append(got, r)
 	}
 
-	licensObj := models.Licenses{}
+	licensObj := alp.Licenses{}
 	licensObj.Licenses = got
 
 	return licensObj, nil
 
 }
+
+func scanLicense(row scanner) (alp.License, error) {
+	var licObj alp.License
+	var maxVersion sql.NullInt64
+	var updatesUntil, expiresAt sql.NullTime
+	var entitlements string
+
+	err := row.Scan(&licObj.Id,
+		&licObj.LicenseKey,
+		&licObj.Product,
+		&licObj.Email,
+		&licObj.Valid,
+		&licObj.Suspended,
+		&maxVersion,
+		&updatesUntil,
+		&expiresAt,
+		&entitlements,
+		&licObj.MaxActivations,
+		&licObj.Revision)
+	if err != nil {
+		return alp.License{}, err
+	}
+
+	licObj.MaxVersion = int(maxVersion.Int64)
+	if updatesUntil.Valid {
+		licObj.UpdatesUntil = &updatesUntil.Time
+	}
+	if expiresAt.Valid {
+		licObj.ExpiresAt = &expiresAt.Time
+	}
+	if entitlements != "" {
+		licObj.Entitlements = strings.Split(entitlements, ",")
+	}
+	return licObj, nil
+}
+
+func InsertLicense(db *sql.DB, tenantID int, lic alp.License) error {
+	var maxVersion sql.NullInt64
+	if lic.MaxVersion > 0 {
+		maxVersion = sql.NullInt64{Int64: int64(lic.MaxVersion), Valid: true}
+	}
+	var updatesUntil sql.NullTime
+	if lic.UpdatesUntil != nil {
+		updatesUntil = sql.NullTime{Time: *lic.UpdatesUntil, Valid: true}
+	}
+	var expiresAt sql.NullTime
+	if lic.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *lic.ExpiresAt, Valid: true}
+	}
+
+	query, err := db.Prepare("insert licenses SET tenant_id=?, license_key=?, product=?, email=?, max_version=?, updates_until=?, expires_at=?, entitlements=?, max_activations=?")
+	if err != nil {
+		return err
+	}
+	defer query.Close()
+	_, err = query.Exec(tenantID, lic.LicenseKey, lic.Product, lic.Email, maxVersion, updatesUntil, expiresAt, strings.Join(lic.Entitlements, ","), lic.MaxActivations)
+	return err
+}
+
+// GetNewlyExpired returns valid licenses across all tenants that have expired
+// but have not been announced yet, along with their tenant ids.
+func GetNewlyExpired(db *sql.DB, limit int) ([]alp.License, []int, error) {
+	rows, err := db.Query("select "+licenseColumns+", tenant_id from licenses where valid = 1 and expiry_notified = 0 and expires_at <= ? limit ?", time.Now(), limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var licenses []alp.License
+	var tenants []int
+	for rows.Next() {
+		var tenantID int
+		r, err := scanLicense(tenantScanner{rows, &tenantID})
+		if err != nil {
+			return nil, nil, err
+		}
+		licenses = append(licenses, r)
+		tenants = append(tenants, tenantID)
+	}
+	return licenses, tenants, rows.Err()
+}
+
+func MarkExpiryNotified(db *sql.DB, tenantID int, key string) error {
+	_, err := db.Exec("update licenses set expiry_notified = 1 where tenant_id = ? and license_key = ?", tenantID, key)
+	return err
+}
+
+// tenantScanner scans a trailing tenant_id column after the usual ones.
+type tenantScanner struct {
+	row      scanner
+	tenantID *int
+}
+
+func (t tenantScanner) Scan(dest ...interface{}) error {
+	return t.row.Scan(append(dest, t.tenantID)...)
+}
+
+func GetProducts(db *sql.DB, tenantID int) ([]string, error) {
+	rows, err := db.Query("select distinct product from licenses where tenant_id = ? order by product", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []string{}
+	for rows.Next() {
+		var product string
+		if err = rows.Scan(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"errors"
+	"github.com/GreatGodApollo/alp"
+)
+
+var (
+	ErrLicenseNonexistent      = errors.New(alp.MessageLicenseNonexistent)
+	ErrIncorrectProduct        = errors.New(alp.MessageIncorrectProduct)
+	ErrLicenseAlreadyInvalid   = errors.New(alp.MessageLicenseAlreadyInvalid)
+	ErrLicenseAlreadySuspended = errors.New("license already suspended")
+	ErrLicenseNotSuspended     = errors.New("license not suspended")
+	ErrLicenseModified         = errors.New("license was modified")
+	ErrActivationNonexistent   = errors.New("activation nonexistent")
+	ErrActivationsExceeded     = errors.New("activation limit reached")
+	ErrAPIKeyNonexistent       = errors.New(alp.MessageAPIKeyNonexistent)
+	ErrAPIKeyAlreadyRevoked    = errors.New(alp.MessageAPIKeyAlreadyRevoked)
+	ErrUserNonexistent         = errors.New("user nonexistent")
+	ErrTenantNonexistent       = errors.New("tenant nonexistent")
+	ErrWebhookNonexistent      = errors.New("webhook nonexistent")
+	ErrDeliveryNonexistent     = errors.New("delivery nonexistent")
+	ErrDeliveryNotFailed       = errors.New("delivery has not failed")
+)
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/GreatGodApollo/alp"
+)
+
+const (
+	HistoryCreated     = "created"
+	HistoryInvalidated = "invalidated"
+	HistorySuspended   = "suspended"
+	HistoryResumed     = "resumed"
+	HistoryUpdated     = "updated"
+	HistoryActivated   = "activated"
+	HistoryDeactivated = "deactivated"
+)
+
+func RecordHistory(db *sql.DB, tenantID int, key, action, actor, details string) error {
+	query, err := db.Prepare("insert license_history SET tenant_id=?, license_key=?, action=?, actor=?, details=?")
+	if err != nil {
+		return err
+	}
+	defer query.Close()
+	_, err = query.Exec(tenantID, key, action, actor, details)
+	return err
+}
+
+// GetHistory returns the recorded actions on a license, oldest first.
+func GetHistory(db *sql.DB, tenantID int, key string) ([]alp.HistoryEntry, error) {
+	rows, err := db.Query("select action, actor, details, created_at from license_history where tenant_id = ? and license_key = ? order by id", tenantID, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []alp.HistoryEntry{}
+	for rows.Next() {
+		var entry alp.HistoryEntry
+		if err := rows.Scan(&entry.Action, &entry.Actor, &entry.Details, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
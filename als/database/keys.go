@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/GreatGodApollo/alp"
+	"strings"
+	"time"
+)
+
+const apiKeyColumns = "id, tenant_id, name, prefix, scopes, products, expires_at, last_used_at, created_at, revoked"
+
+func InsertAPIKey(db *sql.DB, tenantID int, key alp.APIKey, hash string) (int, error) {
+	var expiresAt sql.NullTime
+	if key.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *key.ExpiresAt, Valid: true}
+	}
+
+	query, err := db.Prepare("insert api_keys SET tenant_id=?, name=?, prefix=?, key_hash=?, scopes=?, products=?, expires_at=?")
+	if err != nil {
+		return 0, err
+	}
+	defer query.Close()
+	res, err := query.Exec(tenantID, key.Name, key.Prefix, hash, strings.Join(key.Scopes, ","), strings.Join(key.Products, ","), expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func GetAPIKeyByHash(db *sql.DB, hash string) (alp.APIKey, error) {
+	key, err := scanAPIKey(db.QueryRow("select "+apiKeyColumns+" from api_keys where key_hash = ?", hash))
+	if err == sql.ErrNoRows {
+		return alp.APIKey{}, ErrAPIKeyNonexistent
+	}
+	return key, err
+}
+
+func GetAPIKey(db *sql.DB, tenantID, id int) (alp.APIKey, error) {
+	key, err := scanAPIKey(db.QueryRow("select "+apiKeyColumns+" from api_keys where tenant_id = ? and id = ?", tenantID, id))
+	if err == sql.ErrNoRows {
+		return alp.APIKey{}, ErrAPIKeyNonexistent
+	}
+	return key, err
+}
+
+func GetAllAPIKeys(db *sql.DB, tenantID int) (alp.APIKeys, error) {
+	rows, err := db.Query("select "+apiKeyColumns+" from api_keys where tenant_id = ? order by id", tenantID)
+	if err != nil {
+		return alp.APIKeys{}, err
+	}
+	defer rows.Close()
+
+	keys := alp.APIKeys{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return alp.APIKeys{}, err
+		}
+		keys.Keys = append(keys.Keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func CountAPIKeys(db *sql.DB, tenantID int) (int, error) {
+	var count int
+	err := db.QueryRow("select count(*) from api_keys where tenant_id = ?", tenantID).Scan(&count)
+	return count, err
+}
+
+// CheckAPIKeyRevocable returns the error revoking the key would give, if
+// any.
+func CheckAPIKeyRevocable(db *sql.DB, tenantID, id int) error {
+	var revoked bool
+	err := db.QueryRow("select revoked from api_keys where tenant_id = ? and id = ?", tenantID, id).Scan(&revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrAPIKeyNonexistent
+		}
+		return err
+	}
+	if revoked {
+		return ErrAPIKeyAlreadyRevoked
+	}
+	return nil
+}
+
+func RevokeAPIKey(db *sql.DB, tenantID, id int) error {
+	if err := CheckAPIKeyRevocable(db, tenantID, id); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("update api_keys set revoked = true where tenant_id = ? and id = ?", tenantID, id)
+	return err
+}
+
+func TouchAPIKey(db *sql.DB, tenantID, id int) error {
+	_, err := db.Exec("update api_keys set last_used_at = ? where tenant_id = ? and id = ?", time.Now(), tenantID, id)
+	return err
+}
+
+func scanAPIKey(row scanner) (alp.APIKey, error) {
+	var key alp.APIKey
+	var scopes, products string
+	var expiresAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(&key.Id,
+		&key.TenantId,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&products,
+		&expiresAt,
+		&lastUsedAt,
+		&key.CreatedAt,
+		&key.Revoked)
+	if err != nil {
+		return alp.APIKey{}, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if products != "" {
+		key.Products = strings.Split(products, ",")
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return key, nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/GreatGodApollo/als/models"
+)
+
+const (
+	DefaultTenantId = 1
+
+	tenantColumns = "id, slug, name, host, crypt_key, sign_key, rate_limit, created_at"
+)
+
+// EnsureDefaultTenant creates the tenant that owns everything created before
+// tenants existed, using the keys from the config file.
+func EnsureDefaultTenant(db *sql.DB, cryptKey, signKey string) error {
+	tenant, err := GetTenant(db, DefaultTenantId)
+	if err == nil {
+		if tenant.SignKey == "" {
+			_, err = db.Exec("update tenants set sign_key = ? where id = ?", signKey, DefaultTenantId)
+		}
+		return err
+	}
+	if !errors.Is(err, ErrTenantNonexistent) {
+		return err
+	}
+	_, err = db.Exec("insert tenants SET id=?, slug=?, name=?, crypt_key=?, sign_key=?", DefaultTenantId, "default", "Default", cryptKey, signKey)
+	return err
+}
+
+func InsertTenant(db *sql.DB, tenant models.Tenant) (int, error) {
+	var host sql.NullString
+	if tenant.Host != "" {
+		host = sql.NullString{String: tenant.Host, Valid: true}
+	}
+
+	query, err := db.Prepare("insert tenants SET slug=?, name=?, host=?, crypt_key=?, sign_key=?, rate_limit=?")
+	if err != nil {
+		return 0, err
+	}
+	defer query.Close()
+	res, err := query.Exec(tenant.Slug, tenant.Name, host, tenant.CryptKey, tenant.SignKey, tenant.RateLimit)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func GetTenant(db *sql.DB, id int) (models.Tenant, error) {
+	return scanTenant(db.QueryRow("select "+tenantColumns+" from tenants where id = ?", id))
+}
+
+func GetTenantBySlug(db *sql.DB, slug string) (models.Tenant, error) {
+	return scanTenant(db.QueryRow("select "+tenantColumns+" from tenants where slug = ?", slug))
+}
+
+func GetTenantByHost(db *sql.DB, host string) (models.Tenant, error) {
+	return scanTenant(db.QueryRow("select "+tenantColumns+" from tenants where host = ?", host))
+}
+
+func GetAllTenants(db *sql.DB) ([]models.Tenant, error) {
+	rows, err := db.Query("select " + tenantColumns + " from tenants order by id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+func UpdateTenantHost(db *sql.DB, id int, host string) error {
+	var h sql.NullString
+	if host != "" {
+		h = sql.NullString{String: host, Valid: true}
+	}
+	_, err := db.Exec("update tenants set host = ? where id = ?", h, id)
+	return err
+}
+
+func UpdateTenantRateLimit(db *sql.DB, id, rateLimit int) error {
+	_, err := db.Exec("update tenants set rate_limit = ? where id = ?", rateLimit, id)
+	return err
+}
+
+func scanTenant(row scanner) (models.Tenant, error) {
+	var tenant models.Tenant
+	var host sql.NullString
+
+	err := row.Scan(&tenant.Id,
+		&tenant.Slug,
+		&tenant.Name,
+		&host,
+		&tenant.CryptKey,
+		&tenant.SignKey,
+		&tenant.RateLimit,
+		&tenant.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Tenant{}, ErrTenantNonexistent
+		}
+		return models.Tenant{}, err
+	}
+	tenant.Host = host.String
+	return tenant, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/GreatGodApollo/als/models"
+)
+
+const userColumns = "id, username, role, disabled, created_at"
+
+func InsertUser(db *sql.DB, tenantID int, username, passwordHash, role string) error {
+	query, err := db.Prepare("insert users SET tenant_id=?, username=?, password_hash=?, role=?")
+	if err != nil {
+		return err
+	}
+	defer query.Close()
+	_, err = query.Exec(tenantID, username, passwordHash, role)
+	return err
+}
+
+// GetUserCredentials returns the user along with their password hash.
+func GetUserCredentials(db *sql.DB, tenantID int, username string) (models.User, string, error) {
+	var user models.User
+	var hash string
+	err := db.QueryRow("select "+userColumns+", password_hash from users where tenant_id = ? and username = ?", tenantID, username).Scan(&user.Id,
+		&user.Username,
+		&user.Role,
+		&user.Disabled,
+		&user.CreatedAt,
+		&hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.User{}, "", ErrUserNonexistent
+		}
+		return models.User{}, "", err
+	}
+	return user, hash, nil
+}
+
+func GetAllUsers(db *sql.DB, tenantID int) ([]models.User, error) {
+	rows, err := db.Query("select "+userColumns+" from users where tenant_id = ? order by username", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		err = rows.Scan(&user.Id,
+			&user.Username,
+			&user.Role,
+			&user.Disabled,
+			&user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func CountUsers(db *sql.DB, tenantID int) (int, error) {
+	var count int
+	err := db.QueryRow("select count(*) from users where tenant_id = ?", tenantID).Scan(&count)
+	return count, err
+}
+
+func DeleteUser(db *sql.DB, tenantID int, username string) error {
+	return updateUser(db, tenantID, username, "delete from users where tenant_id = ? and username = ?")
+}
+
+func UpdateUserPassword(db *sql.DB, tenantID int, username, passwordHash string) error {
+	return updateUser(db, tenantID, username, "update users set password_hash = ? where tenant_id = ? and username = ?", passwordHash)
+}
+
+func UpdateUserRole(db *sql.DB, tenantID int, username, role string) error {
+	return updateUser(db, tenantID, username, "update users set role = ? where tenant_id = ? and username = ?", role)
+}
+
+func SetUserDisabled(db *sql.DB, tenantID int, username string, disabled bool) error {
+	return updateUser(db, tenantID, username, "update users set disabled = ? where tenant_id = ? and username = ?", disabled)
+}
+
+// updateUser runs query with args followed by the tenant and username.
+func updateUser(db *sql.DB, tenantID int, username, query string, args ...interface{}) error {
+	if _, _, err := GetUserCredentials(db, tenantID, username); err != nil {
+		return err
+	}
+	_, err := db.Exec(query, append(args, tenantID, username)...)
+	return err
+}
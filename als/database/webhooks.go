@@ -0,0 +1,213 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/GreatGodApollo/alp"
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+
+	webhookColumns  = "id, url, secret, events, active, created_at"
+	deliveryColumns = "id, tenant_id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at"
+)
+
+func InsertWebhook(db *sql.DB, tenantID int, hook alp.Webhook) (int, error) {
+	query, err := db.Prepare("insert webhooks SET tenant_id=?, url=?, secret=?, events=?")
+	if err != nil {
+		return 0, err
+	}
+	defer query.Close()
+	res, err := query.Exec(tenantID, hook.URL, hook.Secret, strings.Join(hook.Events, ","))
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func GetAllWebhooks(db *sql.DB, tenantID int) (alp.Webhooks, error) {
+	return queryWebhooks(db, "select "+webhookColumns+" from webhooks where tenant_id = ? order by id", tenantID)
+}
+
+// GetSubscribedWebhooks returns the tenant's active webhooks that want
+// event. A webhook without an event filter receives every event.
+func GetSubscribedWebhooks(db *sql.DB, tenantID int, event string) ([]alp.Webhook, error) {
+	hooks, err := queryWebhooks(db, "select "+webhookColumns+" from webhooks where tenant_id = ? and active = 1", tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscribed []alp.Webhook
+	for _, hook := range hooks.Webhooks {
+		if len(hook.Events) == 0 {
+			subscribed = append(subscribed, hook)
+			continue
+		}
+		for _, e := range hook.Events {
+			if e == event {
+				subscribed = append(subscribed, hook)
+				break
+			}
+		}
+	}
+	return subscribed, nil
+}
+
+func DeleteWebhook(db *sql.DB, tenantID, id int) error {
+	res, err := db.Exec("delete from webhooks where tenant_id = ? and id = ?", tenantID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWebhookNonexistent
+	}
+	_, err = db.Exec("delete from webhook_deliveries where tenant_id = ? and webhook_id = ? and status = ?", tenantID, id, DeliveryPending)
+	return err
+}
+
+func GetWebhook(db *sql.DB, tenantID, id int) (alp.Webhook, error) {
+	hooks, err := queryWebhooks(db, "select "+webhookColumns+" from webhooks where tenant_id = ? and id = ?", tenantID, id)
+	if err != nil {
+		return alp.Webhook{}, err
+	}
+	if len(hooks.Webhooks) == 0 {
+		return alp.Webhook{}, ErrWebhookNonexistent
+	}
+	return hooks.Webhooks[0], nil
+}
+
+func queryWebhooks(db *sql.DB, query string, args ...interface{}) (alp.Webhooks, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return alp.Webhooks{}, err
+	}
+	defer rows.Close()
+
+	hooks := alp.Webhooks{}
+	for rows.Next() {
+		var hook alp.Webhook
+		var events string
+		err = rows.Scan(&hook.Id,
+			&hook.URL,
+			&hook.Secret,
+			&events,
+			&hook.Active,
+			&hook.CreatedAt)
+		if err != nil {
+			return alp.Webhooks{}, err
+		}
+		if events != "" {
+			hook.Events = strings.Split(events, ",")
+		}
+		hooks.Webhooks = append(hooks.Webhooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func InsertDelivery(db *sql.DB, tenantID, webhookID int, event, payload string) error {
+	query, err := db.Prepare("insert webhook_deliveries SET tenant_id=?, webhook_id=?, event=?, payload=?, next_attempt_at=?")
+	if err != nil {
+		return err
+	}
+	defer query.Close()
+	_, err = query.Exec(tenantID, webhookID, event, payload, time.Now())
+	return err
+}
+
+// GetDueDeliveries returns pending deliveries across all tenants whose next
+// attempt is due. It is only meant for the delivery worker.
+func GetDueDeliveries(db *sql.DB, limit int) ([]alp.WebhookDelivery, error) {
+	return queryDeliveries(db, "select "+deliveryColumns+" from webhook_deliveries where status = ? and next_attempt_at <= ? order by next_attempt_at limit ?",
+		DeliveryPending, time.Now(), limit)
+}
+
+func GetDeadDeliveries(db *sql.DB, tenantID int) (alp.WebhookDeliveries, error) {
+	deliveries, err := queryDeliveries(db, "select "+deliveryColumns+" from webhook_deliveries where tenant_id = ? and status = ? order by id",
+		tenantID, DeliveryDead)
+	return alp.WebhookDeliveries{Deliveries: deliveries}, err
+}
+
+func MarkDelivered(db *sql.DB, tenantID, id int) error {
+	_, err := db.Exec("update webhook_deliveries set status = ?, attempts = attempts + 1, last_error = '', delivered_at = ? where tenant_id = ? and id = ?",
+		DeliveryDelivered, time.Now(), tenantID, id)
+	return err
+}
+
+// MarkFailed records a failed attempt, either scheduling the next one or
+// moving the delivery to the dead letter list when next is nil.
+func MarkFailed(db *sql.DB, tenantID, id int, lastError string, next *time.Time) error {
+	if len(lastError) > 1000 {
+		lastError = lastError[:1000]
+	}
+	if next == nil {
+		_, err := db.Exec("update webhook_deliveries set status = ?, attempts = attempts + 1, last_error = ? where tenant_id = ? and id = ?",
+			DeliveryDead, lastError, tenantID, id)
+		return err
+	}
+	_, err := db.Exec("update webhook_deliveries set attempts = attempts + 1, last_error = ?, next_attempt_at = ? where tenant_id = ? and id = ?",
+		lastError, *next, tenantID, id)
+	return err
+}
+
+// ReplayDelivery queues a delivery that has failed again with a fresh set of
+// attempts. It may be dead or still being retried, but not delivered.
+func ReplayDelivery(db *sql.DB, tenantID, id int) error {
+	deliveries, err := queryDeliveries(db, "select "+deliveryColumns+" from webhook_deliveries where tenant_id = ? and id = ?", tenantID, id)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		return ErrDeliveryNonexistent
+	}
+	res, err := db.Exec("update webhook_deliveries set status = ?, attempts = 0, next_attempt_at = ? where tenant_id = ? and id = ? and (status = ? or (status = ? and attempts > 0))",
+		DeliveryPending, time.Now(), tenantID, id, DeliveryDead, DeliveryPending)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrDeliveryNotFailed
+	}
+	return nil
+}
+
+func queryDeliveries(db *sql.DB, query string, args ...interface{}) ([]alp.WebhookDelivery, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []alp.WebhookDelivery
+	for rows.Next() {
+		var d alp.WebhookDelivery
+		var deliveredAt sql.NullTime
+		err = rows.Scan(&d.Id,
+			&d.TenantId,
+			&d.WebhookId,
+			&d.Event,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttempt,
+			&d.LastError,
+			&d.CreatedAt,
+			&deliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/GreatGodApollo/als/crypto"
 	"github.com/GreatGodApollo/als/database"
 	"github.com/GreatGodApollo/als/server"
 	"github.com/GreatGodApollo/als/utils"
+	"github.com/GreatGodApollo/als/webhooks"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/spf13/viper"
+	"os"
 )
 
 var db *sql.DB
@@ -21,6 +25,12 @@ func init() {
 	viper.SetDefault("server.bind", ":8080")
 	viper.SetDefault("server.production", false)
 
+	// Webhook Defaults
+	viper.SetDefault("webhooks.interval", "10s")
+	viper.SetDefault("webhooks.max_attempts", 8)
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.expiry_interval", "1m")
+
 	// Database Defaults
 	viper.SetDefault("db.username", "root")
 	viper.SetDefault("db.password", "root")
@@ -36,11 +46,27 @@ func init() {
 
 	// Cryptography Key
 	if viper.GetString("crypt.key") == "" {
-		viper.Set("crypt.key", utils.RandomString(16))
+		key, err := crypto.GenerateCryptKey()
+		if err != nil {
+			panic("Could not generate crypto key: " + err.Error())
+		}
+		viper.Set("crypt.key", key)
 		if err := viper.WriteConfig(); err != nil {
 			panic("Could not generate crypto key: " + err.Error())
 		}
 	}
+
+	// Response Signing Key
+	if viper.GetString("sign.key") == "" {
+		key, err := crypto.GenerateSigningKey()
+		if err != nil {
+			panic("Could not generate signing key: " + err.Error())
+		}
+		viper.Set("sign.key", key)
+		if err := viper.WriteConfig(); err != nil {
+			panic("Could not generate signing key: " + err.Error())
+		}
+	}
 }
 
 func main() {
@@ -51,6 +77,31 @@ func main() {
 	}
 	defer db.Close()
 
+	if err := database.EnsureDefaultTenant(db, viper.GetString("crypt.key"), viper.GetString("sign.key")); err != nil {
+		panic("Could not set up default tenant: " + err.Error())
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "users" || os.Args[1] == "tenants") {
+		var code int
+		if os.Args[1] == "users" {
+			code = runUsers(db, os.Args[2:])
+		} else {
+			code = runTenants(db, os.Args[2:])
+		}
+		db.Close()
+		os.Exit(code)
+	}
+
+	dispatcher := webhooks.NewDispatcher(db)
+	dispatcher.Interval = viper.GetDuration("webhooks.interval")
+	dispatcher.MaxAttempts = viper.GetInt("webhooks.max_attempts")
+	dispatcher.Client.Timeout = viper.GetDuration("webhooks.timeout")
+	go dispatcher.Run(context.Background())
+	go utils.WatchExpiry(context.Background(), db, viper.GetDuration("webhooks.expiry_interval"))
+
 	server.Setup(db)
+	if err := server.Bootstrap(); err != nil {
+		panic("Could not bootstrap authentication: " + err.Error())
+	}
 	server.RunAPI()
 }
@@ -0,0 +1,6 @@
+-- Version ranges on licenses. valid has always been read by als but was
+-- missing from database.sql; leave it out if your table already has it.
+ALTER TABLE licenses
+    ADD COLUMN valid boolean not null default true,
+    ADD COLUMN max_version int null,
+    ADD COLUMN updates_until datetime null;
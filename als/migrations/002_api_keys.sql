@@ -0,0 +1,13 @@
+-- Scoped API keys for the admin routes.
+CREATE TABLE api_keys (
+    id int not null unique auto_increment,
+    name varchar(100) not null,
+    prefix varchar(12) not null,
+    key_hash char(64) not null unique,
+    scopes varchar(250) not null,
+    products varchar(1000) not null default '',
+    expires_at datetime null,
+    last_used_at datetime null,
+    created_at datetime not null default current_timestamp,
+    revoked boolean not null default false
+);
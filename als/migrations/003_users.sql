@@ -0,0 +1,20 @@
+-- Admin users and license history. On its next start als imports the
+-- accounts in auth.accounts as owners. History starts with the first change
+-- made after migrating.
+CREATE TABLE users (
+    id int not null unique auto_increment,
+    username varchar(100) not null unique,
+    password_hash varchar(100) not null,
+    role varchar(20) not null,
+    disabled boolean not null default false,
+    created_at datetime not null default current_timestamp
+);
+
+CREATE TABLE license_history (
+    id int not null unique auto_increment,
+    license_key varchar(18) not null,
+    action varchar(50) not null,
+    actor varchar(150) not null,
+    details varchar(1000) not null default '',
+    created_at datetime not null default current_timestamp
+);
@@ -0,0 +1,26 @@
+-- Tenants. Everything that exists moves into tenant 1, which als creates
+-- on its next start with crypt.key from the config file, so existing
+-- license keys still decrypt. Keys and usernames become unique per tenant.
+CREATE TABLE tenants (
+    id int not null unique auto_increment,
+    slug varchar(50) not null unique,
+    name varchar(250) not null,
+    host varchar(250) null unique,
+    crypt_key varchar(32) not null,
+    rate_limit int not null default 10,
+    created_at datetime not null default current_timestamp
+);
+
+ALTER TABLE licenses ADD COLUMN tenant_id int not null default 1 AFTER id;
+UPDATE licenses SET tenant_id = 1;
+ALTER TABLE licenses DROP INDEX license_key, ADD UNIQUE (tenant_id, license_key);
+
+ALTER TABLE api_keys ADD COLUMN tenant_id int not null default 1 AFTER id;
+UPDATE api_keys SET tenant_id = 1;
+
+ALTER TABLE users ADD COLUMN tenant_id int not null default 1 AFTER id;
+UPDATE users SET tenant_id = 1;
+ALTER TABLE users DROP INDEX username, ADD UNIQUE (tenant_id, username);
+
+ALTER TABLE license_history ADD COLUMN tenant_id int not null default 1 AFTER id;
+UPDATE license_history SET tenant_id = 1;
@@ -0,0 +1,28 @@
+-- Webhooks, and license expiry, which they announce.
+ALTER TABLE licenses
+    ADD COLUMN expires_at datetime null AFTER updates_until,
+    ADD COLUMN expiry_notified boolean not null default false AFTER expires_at;
+
+CREATE TABLE webhooks (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    url varchar(1000) not null,
+    secret varchar(64) not null,
+    events varchar(1000) not null default '',
+    active boolean not null default true,
+    created_at datetime not null default current_timestamp
+);
+
+CREATE TABLE webhook_deliveries (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    webhook_id int not null,
+    event varchar(50) not null,
+    payload text not null,
+    status varchar(20) not null default 'pending',
+    attempts int not null default 0,
+    next_attempt_at datetime not null default current_timestamp,
+    last_error varchar(1000) not null default '',
+    created_at datetime not null default current_timestamp,
+    delivered_at datetime null
+);
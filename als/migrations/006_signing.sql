@@ -0,0 +1,3 @@
+-- Per-tenant keys for signing check responses. als fills in the default
+-- tenant's from sign.key on its next start.
+ALTER TABLE tenants ADD COLUMN sign_key varchar(64) not null default '' AFTER crypt_key;
@@ -0,0 +1,2 @@
+-- Entitlements, a comma separated list of the features a license unlocks.
+ALTER TABLE licenses ADD COLUMN entitlements varchar(1000) not null default '' AFTER expires_at;
@@ -0,0 +1,2 @@
+-- Suspending licenses without invalidating them.
+ALTER TABLE licenses ADD COLUMN suspended boolean not null default false AFTER valid;
@@ -0,0 +1,14 @@
+-- Machine activations, and the revision the v2 routes use for ETags.
+ALTER TABLE licenses
+    ADD COLUMN max_activations int not null default 0,
+    ADD COLUMN revision int not null default 0;
+
+CREATE TABLE activations (
+    id int not null unique auto_increment,
+    tenant_id int not null default 1,
+    license_id int not null,
+    fingerprint varchar(55) not null,
+    created_at datetime not null default current_timestamp,
+    last_seen_at datetime not null default current_timestamp,
+    unique (tenant_id, license_id, fingerprint)
+);
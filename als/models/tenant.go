@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Tenant struct {
+	Id        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Host      string    `json:"host,omitempty"`
+	CryptKey  string    `json:"-"`
+	SignKey   string    `json:"-"`
+	RateLimit int       `json:"rate_limit"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type User struct {
+	Id        int       `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
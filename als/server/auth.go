@@ -0,0 +1,173 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const principalKey = "principal"
+
+// Bootstrap imports any deprecated config accounts as owners and, when the
+// server has no way of being administered yet, mints an all-powerful key so
+// the first real users and keys can be created.
+func Bootstrap() error {
+	users, err := database.CountUsers(db, database.DefaultTenantId)
+	if err != nil {
+		return err
+	}
+
+	accounts := viper.GetStringMapString("auth.accounts")
+	if len(accounts) > 0 {
+		if users == 0 {
+			for username, password := range accounts {
+				hash, err := auth.HashPassword(password)
+				if err != nil {
+					return err
+				}
+				if err = database.InsertUser(db, database.DefaultTenantId, username, hash, auth.RoleOwner); err != nil {
+					return err
+				}
+				users++
+			}
+			fmt.Println("Imported auth.accounts as owners, remove them from the config file")
+		} else {
+			fmt.Println("Warning: auth.accounts is ignored, manage admins with `als users`")
+		}
+	}
+
+	keys, err := database.CountAPIKeys(db, database.DefaultTenantId)
+	if err != nil {
+		return err
+	}
+	if users > 0 || keys > 0 {
+		return nil
+	}
+
+	key, _, err := utils.MintAPIKey(db, database.DefaultTenantId, alp.APIKey{
+		Name:   "bootstrap",
+		Scopes: auth.AllScopes,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Created bootstrap api key, it will not be shown again:", key)
+	return nil
+}
+
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := requestAPIKey(c); key != "" {
+			apiKey, err := database.GetAPIKeyByHash(db, auth.HashAPIKey(key))
+			if err != nil && !errors.Is(err, database.ErrAPIKeyNonexistent) {
+				handleError(c, err)
+				c.Abort()
+				return
+			}
+			if err != nil || apiKey.Revoked || (apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now())) {
+				abortUnauthorized(c)
+				return
+			}
+
+			// The key decides the tenant, unless the host already did.
+			if tenant := getTenant(c); tenant.Id != apiKey.TenantId {
+				if c.GetBool(tenantHostKey) {
+					abortUnauthorized(c)
+					return
+				}
+				tenant, err = database.GetTenant(db, apiKey.TenantId)
+				if handleError(c, err) {
+					c.Abort()
+					return
+				}
+				c.Set(tenantKey, tenant)
+			}
+
+			if handleError(c, database.TouchAPIKey(db, apiKey.TenantId, apiKey.Id)) {
+				c.Abort()
+				return
+			}
+
+			c.Set(principalKey, auth.Principal{
+				Name:     apiKey.Name,
+				KeyId:    apiKey.Id,
+				Scopes:   apiKey.Scopes,
+				Products: apiKey.Products,
+			})
+			c.Next()
+			return
+		}
+
+		if username, password, ok := c.Request.BasicAuth(); ok {
+			user, hash, err := database.GetUserCredentials(db, getTenant(c).Id, username)
+			if err != nil && !errors.Is(err, database.ErrUserNonexistent) {
+				handleError(c, err)
+				c.Abort()
+				return
+			}
+			// The password is checked even for missing or disabled users,
+			// so all failures take as long. A missing user has no hash.
+			if auth.CheckPassword(hash, password) && !user.Disabled {
+				c.Set(principalKey, auth.Principal{
+					Name:   user.Username,
+					Scopes: auth.RoleScopes(user.Role),
+				})
+				c.Next()
+				return
+			}
+		}
+
+		abortUnauthorized(c)
+	}
+}
+
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !getPrincipal(c).Can(scope) {
+			respondError(c, http.StatusForbidden, alp.ErrorForbidden, "missing scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+func getPrincipal(c *gin.Context) auth.Principal {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(auth.Principal)
+	}
+	return auth.Principal{}
+}
+
+// allowProduct writes a forbidden response and returns false when the
+// caller is restricted from the given product.
+func allowProduct(c *gin.Context, product string) bool {
+	if getPrincipal(c).CanProduct(product) {
+		return true
+	}
+	respondError(c, http.StatusForbidden, alp.ErrorForbidden, "not allowed for product "+product)
+	return false
+}
+
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return ""
+}
+
+func abortUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+	respondError(c, http.StatusUnauthorized, alp.ErrorUnauthorized, "unauthorized")
+}
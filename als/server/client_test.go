@@ -0,0 +1,136 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/GreatGodApollo/ala/api"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/alp/openapi"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+// RoundTrip lets ala's client talk to the server through the contract,
+// checking the requests it sends as well as the responses it gets.
+func (c *contract) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+	}
+	withBody := func() *http.Request {
+		clone := r.Clone(r.Context())
+		if r.Body != nil {
+			clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		return clone
+	}
+
+	route, params, ok := openapi.Match(r.Method, r.URL.Path)
+	if !ok {
+		c.t.Errorf("%s %s is not in the OpenAPI document", r.Method, r.URL.Path)
+		return http.DefaultTransport.RoundTrip(withBody())
+	}
+	if err := openapi.ValidateRequest(withBody(), route, params); err != nil {
+		c.t.Errorf("request %s %s: %v", r.Method, r.URL, err)
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(withBody())
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	if err = openapi.ValidateResponse(r.Method, route, resp.StatusCode, respBody); err != nil {
+		c.t.Errorf("response to %s %s: %v: %s", r.Method, r.URL, err, respBody)
+	}
+	c.hit[r.Method+" "+route] = true
+	return resp, nil
+}
+
+func TestClientMatchesHandlers(t *testing.T) {
+	c := newContract(t)
+	defer c.s.Close()
+
+	client := api.NewClient(
+		api.WithBaseURL(c.s.URL),
+		api.WithBasicAuth(c.s.Username, c.s.Password),
+		api.WithPublicKey(c.s.PublicKey),
+		api.WithHTTPClient(&http.Client{Transport: c}),
+	)
+	ctx := context.Background()
+
+	if _, err := client.DryRun().Create(ctx, alp.LicenseRequest{Email: "a@example.com", Product: "prod"}); err != nil {
+		t.Error(err)
+	}
+	created, err := client.Create(ctx, alp.LicenseRequest{Email: "a@example.com", Product: "prod", MaxVersion: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := created.LicenseKey
+
+	if _, err = client.Get(ctx, key); err != nil {
+		t.Error(err)
+	}
+	if _, err = client.All(ctx, "prod"); err != nil {
+		t.Error(err)
+	}
+	if _, err = client.Licenses(ctx, "prod"); err != nil {
+		t.Error(err)
+	}
+	if _, err = client.Products(ctx); err != nil {
+		t.Error(err)
+	}
+	if _, err = client.PublicKey(ctx); err != nil {
+		t.Error(err)
+	}
+	if resp, err := client.Check(ctx, alp.CheckRequest{Key: key, Product: "prod", Version: "2.1.0"}); err != nil || resp.Status != alp.StatusValid {
+		t.Errorf("Check = %v, %v", resp.Status, err)
+	}
+	if valid, err := client.Valid(ctx, key, "other"); err != nil || valid {
+		t.Errorf("Valid for another product = %v, %v", valid, err)
+	}
+
+	if _, err = client.DryRun().Suspend(ctx, key); err != nil {
+		t.Error(err)
+	}
+	for _, action := range []func(context.Context, string) (alp.LicenseResponse, error){client.Suspend, client.Resume, client.Invalidate} {
+		if _, err = action(ctx, key); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err = client.History(ctx, key); err != nil {
+		t.Error(err)
+	}
+
+	apiKey, err := client.CreateAPIKey(ctx, alp.APIKeyRequest{Name: "ci", Scopes: []string{"licenses:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.APIKeys(ctx); err != nil {
+		t.Error(err)
+	}
+	if _, err = client.RevokeAPIKey(ctx, apiKey.APIKey.Id); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = client.Get(ctx, "bm9uZQ=="); err == nil {
+		t.Error("Get of a malformed key succeeded")
+	}
+	if _, err = client.RevokeAPIKey(ctx, 1000); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("RevokeAPIKey of a missing key: %v", err)
+	}
+	other := api.NewClient(api.WithBaseURL(c.s.URL), api.WithBasicAuth(c.s.Username, "wrong"), api.WithHTTPClient(&http.Client{Transport: c}))
+	if _, err = other.Products(ctx); err == nil {
+		t.Error("Products with the wrong password succeeded")
+	}
+}
@@ -0,0 +1,245 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/alp/openapi"
+	"github.com/GreatGodApollo/als/server"
+	"github.com/GreatGodApollo/als/servertest"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contract sends requests to the real handlers and checks every response
+// against the OpenAPI document.
+type contract struct {
+	t   *testing.T
+	s   *servertest.Server
+	hit map[string]bool
+}
+
+// call sends body as JSON and decodes the response into out, failing
+// unless the status is want. Requests log in as the owner unless header
+// sets Authorization, to "" for none.
+func (c *contract) call(want int, method, path string, body, out interface{}, header ...string) http.Header {
+	c.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, c.s.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(c.s.Username, c.s.Password)
+	for i := 0; i+1 < len(header); i += 2 {
+		if header[i] == "Authorization" && header[i+1] == "" {
+			req.Header.Del("Authorization")
+			continue
+		}
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if resp.StatusCode != want {
+		c.t.Errorf("%s %s: status %d, want %d: %s", method, path, resp.StatusCode, want, respBody)
+	}
+	if route, _, ok := openapi.Match(method, req.URL.Path); ok {
+		c.hit[method+" "+route] = true
+		if err = openapi.ValidateResponse(method, route, resp.StatusCode, respBody); err != nil {
+			c.t.Errorf("%s %s: %v: %s", method, path, err, respBody)
+		}
+	}
+	if out != nil && len(respBody) > 0 {
+		if err = json.Unmarshal(respBody, out); err != nil {
+			c.t.Errorf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.Header
+}
+
+func newContract(t *testing.T) *contract {
+	dsn := os.Getenv(servertest.DSNVariable)
+	if dsn == "" {
+		t.Skip(servertest.DSNVariable + " is not set")
+	}
+	s, err := servertest.NewServer(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &contract{t: t, s: s, hit: map[string]bool{}}
+}
+
+func TestHandlersMatchDocument(t *testing.T) {
+	c := newContract(t)
+	defer c.s.Close()
+
+	c.call(http.StatusOK, "GET", "/", nil, nil)
+	c.call(http.StatusOK, "GET", "/openapi.json", nil, nil)
+	c.call(http.StatusNotFound, "GET", "/nowhere", nil, nil)
+	c.call(http.StatusOK, "GET", "/license/pubkey", nil, nil)
+
+	// v1 licenses
+	expires := time.Now().AddDate(1, 0, 0).Format(alp.DateLayout)
+	var created alp.LicenseResponse
+	c.call(http.StatusOK, "POST", "/api/v1/create?dry_run=true", alp.LicenseRequest{Email: "a@example.com", Product: "prod"}, nil)
+	c.call(http.StatusCreated, "POST", "/api/v1/create", alp.LicenseRequest{
+		Email: "a@example.com", Product: "prod", MaxVersion: 2, ExpiresAt: expires, Entitlements: []string{"export"},
+	}, &created)
+	key := created.LicenseKey
+	var gone alp.LicenseResponse
+	c.call(http.StatusCreated, "POST", "/api/v1/create", alp.LicenseRequest{Email: "gone@example.com", Product: "prod"}, &gone)
+	if _, err := c.s.DB.Exec("delete from licenses where email = ?", "gone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	c.call(http.StatusUnauthorized, "POST", "/api/v1/create", alp.LicenseRequest{Email: "a@example.com", Product: "prod"}, nil, "Authorization", "")
+	c.call(http.StatusBadRequest, "POST", "/api/v1/create", map[string]string{"product": "prod"}, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/specific", alp.BasicRequest{Key: key}, nil)
+	c.call(http.StatusNotFound, "POST", "/api/v1/specific", alp.BasicRequest{Key: gone.LicenseKey}, nil)
+	c.call(http.StatusBadRequest, "POST", "/api/v1/specific", alp.BasicRequest{Key: "bm9uZQ=="}, nil)
+	c.call(http.StatusOK, "GET", "/api/v1/all/prod", nil, nil)
+	c.call(http.StatusOK, "GET", "/api/v1/products", nil, nil)
+
+	c.call(http.StatusOK, "POST", "/license/check", alp.CheckRequest{Key: key, Product: "prod", Version: "2.1.0", Nonce: "bm9uY2U"}, nil)
+	c.call(http.StatusOK, "POST", "/license/check", alp.CheckRequest{Key: key, Product: "prod", Version: "3.0.0"}, nil)
+	c.call(http.StatusOK, "POST", "/license/check", alp.CheckRequest{Key: key, Product: "other"}, nil)
+	c.call(http.StatusBadRequest, "POST", "/license/check", alp.CheckRequest{Key: key, Product: "prod", Version: "x"}, nil)
+	c.call(http.StatusNotFound, "POST", "/license/check", alp.CheckRequest{Key: gone.LicenseKey, Product: "prod"}, nil)
+	c.call(http.StatusBadRequest, "POST", "/license/check", map[string]string{"key": key}, nil)
+
+	// Webhooks, created before the changes below so they queue deliveries.
+	var hook struct {
+		Webhook alp.Webhook `json:"webhook"`
+	}
+	c.call(http.StatusOK, "POST", "/api/v1/webhooks/create?dry_run=true", alp.WebhookRequest{URL: "http://127.0.0.1:1/hook"}, nil)
+	c.call(http.StatusCreated, "POST", "/api/v1/webhooks/create", alp.WebhookRequest{URL: "http://127.0.0.1:1/hook"}, &hook)
+	c.call(http.StatusBadRequest, "POST", "/api/v1/webhooks/create", alp.WebhookRequest{URL: "ftp://example.com"}, nil)
+	c.call(http.StatusOK, "GET", "/api/v1/webhooks/all", nil, nil)
+
+	for _, path := range []string{"/api/v1/suspend", "/api/v1/suspend", "/api/v1/resume", "/api/v1/resume"} {
+		c.call(http.StatusOK, "POST", path+"?dry_run=true", alp.BasicRequest{Key: key}, nil)
+		c.call(http.StatusOK, "POST", path, alp.BasicRequest{Key: key}, nil)
+	}
+	c.call(http.StatusOK, "POST", "/api/v1/history", alp.BasicRequest{Key: key}, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/invalidate?dry_run=true", alp.BasicRequest{Key: key}, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/invalidate", alp.BasicRequest{Key: key}, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/invalidate", alp.BasicRequest{Key: key}, nil)
+	c.call(http.StatusNotFound, "POST", "/api/v1/invalidate", alp.BasicRequest{Key: gone.LicenseKey}, nil)
+
+	var delivery int
+	if err := c.s.DB.QueryRow("select id from webhook_deliveries order by id limit 1").Scan(&delivery); err != nil {
+		t.Fatal(err)
+	}
+	c.call(http.StatusOK, "GET", "/api/v1/webhooks/dead", nil, nil)
+	c.call(http.StatusConflict, "POST", "/api/v1/webhooks/replay", alp.RevokeRequest{Id: delivery}, nil)
+	c.call(http.StatusNotFound, "POST", "/api/v1/webhooks/replay", alp.RevokeRequest{Id: delivery + 1000}, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/webhooks/delete", alp.RevokeRequest{Id: hook.Webhook.Id}, nil)
+	c.call(http.StatusNotFound, "POST", "/api/v1/webhooks/delete", alp.RevokeRequest{Id: hook.Webhook.Id}, nil)
+
+	// API keys
+	var restricted alp.APIKeyResponse
+	c.call(http.StatusOK, "POST", "/api/v1/keys/create?dry_run=true", alp.APIKeyRequest{Name: "ci", Scopes: []string{"licenses:read"}}, nil)
+	c.call(http.StatusCreated, "POST", "/api/v1/keys/create", alp.APIKeyRequest{
+		Name: "ci", Scopes: []string{"licenses:read", "keys:admin"}, Products: []string{"prod"},
+	}, &restricted)
+	c.call(http.StatusBadRequest, "POST", "/api/v1/keys/create", alp.APIKeyRequest{Name: "ci", Scopes: []string{"everything"}}, nil)
+	bearer := "Bearer " + restricted.Key
+	c.call(http.StatusOK, "GET", "/api/v1/products", nil, nil, "Authorization", bearer)
+	c.call(http.StatusForbidden, "POST", "/api/v1/create", alp.LicenseRequest{Email: "a@example.com", Product: "prod"}, nil, "Authorization", bearer)
+	c.call(http.StatusForbidden, "POST", "/api/v1/keys/create", alp.APIKeyRequest{Name: "wide", Scopes: []string{"licenses:read"}}, nil, "Authorization", bearer)
+
+	var unrestricted alp.APIKeyResponse
+	c.call(http.StatusCreated, "POST", "/api/v1/keys/create", alp.APIKeyRequest{Name: "all", Scopes: []string{"licenses:read"}}, &unrestricted)
+	var keys alp.APIKeys
+	c.call(http.StatusOK, "GET", "/api/v1/keys/all", nil, &keys, "Authorization", bearer)
+	for _, k := range keys.Keys {
+		if k.Id == unrestricted.APIKey.Id {
+			t.Error("a key restricted to prod can see an unrestricted key")
+		}
+	}
+	c.call(http.StatusForbidden, "POST", "/api/v1/keys/revoke", alp.RevokeRequest{Id: unrestricted.APIKey.Id}, nil, "Authorization", bearer)
+	c.call(http.StatusOK, "GET", "/api/v1/keys/all", nil, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/keys/revoke?dry_run=true", alp.RevokeRequest{Id: unrestricted.APIKey.Id}, nil)
+	c.call(http.StatusOK, "POST", "/api/v1/keys/revoke", alp.RevokeRequest{Id: unrestricted.APIKey.Id}, nil)
+	c.call(http.StatusConflict, "POST", "/api/v1/keys/revoke", alp.RevokeRequest{Id: unrestricted.APIKey.Id}, nil)
+	c.call(http.StatusNotFound, "POST", "/api/v1/keys/revoke", alp.RevokeRequest{Id: 1000}, nil)
+	c.call(http.StatusUnauthorized, "GET", "/api/v1/products", nil, nil, "Authorization", "Bearer "+unrestricted.Key)
+
+	// v2 licenses
+	var lic alp.License
+	create := alp.LicenseCreate{LicenseRequest: alp.LicenseRequest{Email: "b@example.com", Product: "prod"}, MaxActivations: 1}
+	c.call(http.StatusBadRequest, "POST", "/api/v2/licenses?dry_run=true", create, nil)
+	header := c.call(http.StatusCreated, "POST", "/api/v2/licenses", create, &lic)
+	etag := header.Get("ETag")
+	path := fmt.Sprintf("/api/v2/licenses/%d", lic.Id)
+	c.call(http.StatusOK, "GET", "/api/v2/licenses?product=prod", nil, nil)
+	c.call(http.StatusBadRequest, "GET", "/api/v2/licenses", nil, nil)
+	c.call(http.StatusOK, "GET", path, nil, nil)
+	c.call(http.StatusNotModified, "GET", path, nil, nil, "If-None-Match", etag)
+	c.call(http.StatusNotFound, "GET", "/api/v2/licenses/1000", nil, nil)
+	c.call(http.StatusBadRequest, "GET", "/api/v2/licenses/0", nil, nil)
+
+	email := "c@example.com"
+	c.call(http.StatusOK, "PATCH", path, alp.LicensePatch{Email: &email}, nil, "If-Match", etag)
+	c.call(http.StatusPreconditionFailed, "PATCH", path, alp.LicensePatch{Email: &email}, nil, "If-Match", etag)
+	c.call(http.StatusOK, "GET", path+"/history", nil, nil)
+
+	var activation alp.ActivationResponse
+	first := alp.ActivationRequest{Fingerprint: "fp2.0123456789abcdef.0123456789abcdef.0123456789abcdef"}
+	c.call(http.StatusCreated, "POST", path+"/activations", first, &activation)
+	c.call(http.StatusOK, "POST", path+"/activations", first, nil)
+	c.call(http.StatusConflict, "POST", path+"/activations", alp.ActivationRequest{Fingerprint: "fp2.fedcba9876543210.fedcba9876543210.fedcba9876543210"}, nil)
+	c.call(http.StatusBadRequest, "POST", path+"/activations", alp.ActivationRequest{Fingerprint: "fp1.x"}, nil)
+	c.call(http.StatusOK, "GET", path+"/activations", nil, nil)
+	c.call(http.StatusBadRequest, "DELETE", fmt.Sprintf("%s/activations/%d?dry_run=true", path, activation.Activation.Id), nil, nil)
+	c.call(http.StatusOK, "DELETE", fmt.Sprintf("%s/activations/%d", path, activation.Activation.Id), nil, nil)
+	c.call(http.StatusNotFound, "DELETE", fmt.Sprintf("%s/activations/%d", path, activation.Activation.Id), nil, nil)
+
+	c.call(http.StatusOK, "DELETE", path, nil, nil)
+	c.call(http.StatusConflict, "DELETE", path, nil, nil)
+
+	for _, route := range server.Router().Routes() {
+		if !c.hit[route.Method+" "+openapi.Path(route.Path)] {
+			t.Errorf("%s %s was not exercised", route.Method, route.Path)
+		}
+	}
+}
+
+func TestRateLimitedCheckMatchesDocument(t *testing.T) {
+	c := newContract(t)
+	defer c.s.Close()
+	if _, err := c.s.DB.Exec("update tenants set rate_limit = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	check := alp.CheckRequest{Key: "bm9uZQ==", Product: "prod"}
+	c.call(http.StatusBadRequest, "POST", "/license/check", check, nil)
+	header := c.call(http.StatusTooManyRequests, "POST", "/license/check", check, nil)
+	if !strings.ContainsAny(header.Get("Retry-After"), "0123456789") {
+		t.Errorf("Retry-After = %q", header.Get("Retry-After"))
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"github.com/GreatGodApollo/alp"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// respondError ends the request with the error envelope every failure
+// shares.
+func respondError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, alp.ErrorResponse{
+		Status:  alp.StatusError,
+		Error:   code,
+		Message: message,
+		Code:    status,
+	})
+}
+
+// handleError responds with an internal error when err is set. What went
+// wrong is only logged, as it may describe the database or keys.
+func handleError(c *gin.Context, err error) bool {
+	if err != nil {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+		respondError(c, http.StatusInternalServerError, alp.ErrorInternal, "internal server error")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func CreateKeyRouter(c *gin.Context) {
+	var req alp.APIKeyRequest
+
+	if c.ShouldBind(&req) == nil {
+		for _, scope := range req.Scopes {
+			if !auth.ValidScope(scope) {
+				respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "unknown scope "+scope)
+				return
+			}
+		}
+		if !canGrant(c, req) {
+			return
+		}
+		expiresAt, err := alp.ParseDate(req.ExpiresAt)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid expiry date")
+			return
+		}
+
+		if dryRun(c) {
+			c.JSON(http.StatusOK, alp.APIKeyResponse{
+				APIKey:  alp.APIKey{Name: req.Name, Scopes: req.Scopes, Products: req.Products, ExpiresAt: expiresAt},
+				Status:  alp.StatusDryRun,
+				Message: "api key would be created",
+				Code:    http.StatusOK,
+			})
+			return
+		}
+
+		key, apiKey, err := utils.MintAPIKey(db, getTenant(c).Id, alp.APIKey{
+			Name:      req.Name,
+			Scopes:    req.Scopes,
+			Products:  req.Products,
+			ExpiresAt: expiresAt,
+		})
+		if handleError(c, err) {
+			return
+		}
+
+		c.JSON(http.StatusCreated, alp.APIKeyResponse{
+			Key:     key,
+			APIKey:  apiKey,
+			Status:  alp.StatusCreated,
+			Message: "api key created",
+			Code:    http.StatusCreated,
+		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+func GetKeysRouter(c *gin.Context) {
+	keys, err := database.GetAllAPIKeys(db, getTenant(c).Id)
+	if handleError(c, err) {
+		return
+	}
+	// A key restricted to some products only sees keys restricted to them.
+	principal := getPrincipal(c)
+	visible := keys.Keys[:0]
+	for _, key := range keys.Keys {
+		if uncovered(principal, nil, key.Products) == "" {
+			visible = append(visible, key)
+		}
+	}
+	keys.Keys = visible
+	keys.Code = http.StatusOK
+	c.JSON(http.StatusOK, keys)
+}
+
+func RevokeKeyRouter(c *gin.Context) {
+	var req alp.RevokeRequest
+	if c.ShouldBind(&req) == nil {
+		key, err := database.GetAPIKey(db, getTenant(c).Id, req.Id)
+		if errors.Is(err, database.ErrAPIKeyNonexistent) {
+			respondError(c, http.StatusNotFound, alp.ErrorNotFound, err.Error())
+			return
+		}
+		if handleError(c, err) {
+			return
+		}
+		// Revoking a key takes as much as creating it would.
+		if what := uncovered(getPrincipal(c), key.Scopes, key.Products); what != "" {
+			respondError(c, http.StatusForbidden, alp.ErrorForbidden, "cannot revoke a key granting "+what)
+			return
+		}
+
+		if dryRun(c) {
+			err = database.CheckAPIKeyRevocable(db, getTenant(c).Id, req.Id)
+		} else {
+			err = database.RevokeAPIKey(db, getTenant(c).Id, req.Id)
+		}
+		if errors.Is(err, database.ErrAPIKeyNonexistent) {
+			respondError(c, http.StatusNotFound, alp.ErrorNotFound, err.Error())
+			return
+		} else if errors.Is(err, database.ErrAPIKeyAlreadyRevoked) {
+			respondError(c, http.StatusConflict, alp.ErrorConflict, err.Error())
+			return
+		}
+		if handleError(c, err) {
+			return
+		}
+
+		if dryRun(c) {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  alp.StatusDryRun,
+				"message": "api key would be revoked",
+				"code":    http.StatusOK,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":  alp.StatusRevoked,
+			"message": "api key revoked",
+			"code":    http.StatusOK,
+		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+// canGrant responds with an error unless the caller holds everything the
+// key would grant, so a key can't be used to mint a more powerful one.
+func canGrant(c *gin.Context, req alp.APIKeyRequest) bool {
+	if what := uncovered(getPrincipal(c), req.Scopes, req.Products); what != "" {
+		respondError(c, http.StatusForbidden, alp.ErrorForbidden, "cannot grant "+what)
+		return false
+	}
+	return true
+}
+
+// uncovered returns what of scopes and products principal doesn't hold, or
+// "" if it holds them all. No products means every product.
+func uncovered(principal auth.Principal, scopes, products []string) string {
+	for _, scope := range scopes {
+		if !principal.Can(scope) {
+			return "scope " + scope
+		}
+	}
+	if len(principal.Products) > 0 && len(products) == 0 {
+		return "every product"
+	}
+	for _, product := range products {
+		if !principal.CanProduct(product) {
+			return "product " + product
+		}
+	}
+	return ""
+}
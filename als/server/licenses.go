@@ -0,0 +1,466 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/utils"
+	"github.com/GreatGodApollo/als/webhooks"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The v2 API addresses licenses by id, so keys stay out of URLs, and
+// guards changes with ETags.
+
+func ListLicensesRouter(c *gin.Context) {
+	product := c.Query("product")
+	if product == "" {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+		return
+	}
+	if !allowProduct(c, product) {
+		return
+	}
+	licenses, err := database.GetAllRecords(db, getTenant(c), product)
+	if handleError(c, err) {
+		return
+	}
+	licenses.Code = http.StatusOK
+	c.JSON(http.StatusOK, licenses)
+}
+
+func PostLicenseRouter(c *gin.Context) {
+	tenant := getTenant(c)
+	var req alp.LicenseCreate
+	if c.ShouldBindJSON(&req) != nil {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+		return
+	}
+
+	updatesUntil, err := alp.ParseDate(req.UpdatesUntil)
+	if err != nil || req.MaxVersion < 0 {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid version range")
+		return
+	}
+	expiresAt, err := alp.ParseDate(req.ExpiresAt)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid expiry date")
+		return
+	}
+	if req.MaxActivations < 0 {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid activation limit")
+		return
+	}
+	if !validEntitlements(c, req.Entitlements) || !allowProduct(c, req.Product) {
+		return
+	}
+
+	crypt, err := utils.GenerateEncryptedLicense(db, tenant, alp.License{
+		Product:        req.Product,
+		Email:          req.Email,
+		MaxVersion:     req.MaxVersion,
+		UpdatesUntil:   updatesUntil,
+		ExpiresAt:      expiresAt,
+		Entitlements:   req.Entitlements,
+		MaxActivations: req.MaxActivations,
+	}, getPrincipal(c).Actor())
+	if handleError(c, err) {
+		return
+	}
+	key, err := utils.DecryptLicense(tenant, crypt)
+	if handleError(c, err) {
+		return
+	}
+	lic, err := database.GetWholeRecord(db, tenant.Id, string(key))
+	if handleError(c, err) {
+		return
+	}
+	shown, ok := showLicense(c, lic)
+	if !ok {
+		return
+	}
+
+	notify(tenant, webhooks.EventLicenseCreated, licenseData(c, shown))
+	c.Header("Location", "/api/v2/licenses/"+strconv.Itoa(lic.Id))
+	respondLicense(c, http.StatusCreated, shown)
+}
+
+// GetLicenseRouter answers If-None-Match with 304 when the license hasn't
+// changed.
+func GetLicenseRouter(c *gin.Context) {
+	lic, ok := loadLicense(c)
+	if !ok {
+		return
+	}
+	if header := c.GetHeader("If-None-Match"); header != "" && etagListed(header, lic) {
+		c.Header("ETag", etag(lic))
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if shown, ok := showLicense(c, lic); ok {
+		respondLicense(c, http.StatusOK, shown)
+	}
+}
+
+func PatchLicenseRouter(c *gin.Context) {
+	tenant := getTenant(c)
+	lic, ok := loadLicense(c)
+	if !ok {
+		return
+	}
+	var patch alp.LicensePatch
+	if c.ShouldBindJSON(&patch) != nil {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid license patch")
+		return
+	}
+	if !ifMatch(c, lic) {
+		return
+	}
+	if !lic.Valid {
+		respondError(c, http.StatusConflict, alp.ErrorConflict, alp.MessageLicenseAlreadyInvalid)
+		return
+	}
+	if patch.Suspended != nil && !getPrincipal(c).Can(auth.ScopeLicensesInvalidate) {
+		respondError(c, http.StatusForbidden, alp.ErrorForbidden, "missing scope "+auth.ScopeLicensesInvalidate)
+		return
+	}
+
+	updated, changed, ok := applyPatch(c, lic, patch)
+	if !ok {
+		return
+	}
+	suspend := patch.Suspended != nil && *patch.Suspended != lic.Suspended
+	if suspend {
+		updated.Suspended = *patch.Suspended
+	}
+	if len(changed) == 0 && !suspend {
+		if shown, ok := showLicense(c, lic); ok {
+			respondLicense(c, http.StatusOK, shown)
+		}
+		return
+	}
+
+	action, details := database.HistoryUpdated, ""
+	if len(changed) > 0 {
+		details = "changed " + strings.Join(changed, ", ")
+	}
+	if suspend && updated.Suspended {
+		action = database.HistorySuspended
+	} else if suspend {
+		action = database.HistoryResumed
+	}
+	if !updateLicense(c, updated, action, details) {
+		return
+	}
+	updated.Revision++
+
+	shown, ok := showLicense(c, updated)
+	if !ok {
+		return
+	}
+	if suspend && updated.Suspended {
+		notify(tenant, webhooks.EventLicenseSuspended, licenseData(c, shown))
+	} else if suspend {
+		notify(tenant, webhooks.EventLicenseResumed, licenseData(c, shown))
+	}
+	respondLicense(c, http.StatusOK, shown)
+}
+
+// DeleteLicenseRouter invalidates the license. Licenses are kept on record
+// once created.
+func DeleteLicenseRouter(c *gin.Context) {
+	lic, ok := loadLicense(c)
+	if !ok || !ifMatch(c, lic) {
+		return
+	}
+	if !lic.Valid {
+		respondError(c, http.StatusConflict, alp.ErrorConflict, alp.MessageLicenseAlreadyInvalid)
+		return
+	}
+
+	lic.Valid = false
+	if !updateLicense(c, lic, database.HistoryInvalidated, "") {
+		return
+	}
+	lic.Revision++
+	if shown, ok := showLicense(c, lic); ok {
+		notify(getTenant(c), webhooks.EventLicenseInvalidated, licenseData(c, shown))
+		respondLicense(c, http.StatusOK, shown)
+	}
+}
+
+func GetLicenseHistoryRouter(c *gin.Context) {
+	lic, ok := loadLicense(c)
+	if !ok {
+		return
+	}
+	history, err := database.GetHistory(db, getTenant(c).Id, lic.LicenseKey)
+	if handleError(c, err) {
+		return
+	}
+	if shown, ok := showLicense(c, lic); ok {
+		c.JSON(http.StatusOK, alp.History{
+			LicenseKey: shown.LicenseKey,
+			History:    history,
+			Code:       http.StatusOK,
+		})
+	}
+}
+
+func GetActivationsRouter(c *gin.Context) {
+	lic, ok := loadLicense(c)
+	if !ok {
+		return
+	}
+	activations, err := database.GetActivations(db, getTenant(c).Id, lic.Id)
+	if handleError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, alp.Activations{
+		Code:           http.StatusOK,
+		MaxActivations: lic.MaxActivations,
+		Activations:    activations,
+	})
+}
+
+// PostActivationRouter activates the license on a machine. A machine whose
+// fingerprint matches one already activated keeps its seat, so replacing a
+// network card doesn't use up another.
+func PostActivationRouter(c *gin.Context) {
+	tenant := getTenant(c)
+	lic, ok := loadLicense(c)
+	if !ok {
+		return
+	}
+	var req alp.ActivationRequest
+	if c.ShouldBindJSON(&req) != nil {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+		return
+	}
+	fp, err := alp.ParseFingerprint(req.Fingerprint)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid fingerprint")
+		return
+	}
+	if !lic.Valid {
+		respondError(c, http.StatusConflict, alp.ErrorConflict, alp.MessageLicenseAlreadyInvalid)
+		return
+	}
+
+	activations, err := database.GetActivations(db, tenant.Id, lic.Id)
+	if handleError(c, err) {
+		return
+	}
+	for _, activation := range activations {
+		if known, err := alp.ParseFingerprint(activation.Fingerprint); err == nil && known.Match(fp) {
+			if handleError(c, database.TouchActivation(db, tenant.Id, activation.Id)) {
+				return
+			}
+			activation.LastSeenAt = time.Now()
+			c.JSON(http.StatusOK, alp.ActivationResponse{
+				Activation: activation,
+				Status:     alp.StatusUnchanged,
+				Message:    "machine already activated",
+				Code:       http.StatusOK,
+			})
+			return
+		}
+	}
+
+	activation, err := database.InsertActivation(db, tenant.Id, lic, fp.String(), getPrincipal(c).Actor())
+	if errors.Is(err, database.ErrActivationsExceeded) {
+		if shown, ok := showLicense(c, lic); ok {
+			data := licenseData(c, shown)
+			data.Fingerprint = fp.String()
+			notify(tenant, webhooks.EventLicenseActivationsExceeded, data)
+			respondError(c, http.StatusConflict, alp.ErrorActivationsExceeded,
+				fmt.Sprintf("license is activated on as many machines as it allows (%d)", lic.MaxActivations))
+		}
+		return
+	}
+	if handleLicenseError(c, err) {
+		return
+	}
+	c.JSON(http.StatusCreated, alp.ActivationResponse{
+		Activation: activation,
+		Status:     alp.StatusActivated,
+		Message:    "machine activated",
+		Code:       http.StatusCreated,
+	})
+}
+
+func DeleteActivationRouter(c *gin.Context) {
+	lic, ok := loadLicense(c)
+	if !ok {
+		return
+	}
+	id, ok := pathID(c, "activation")
+	if !ok {
+		return
+	}
+	err := database.DeleteActivation(db, getTenant(c).Id, lic, id, getPrincipal(c).Actor())
+	if handleNotFound(c, err, database.ErrActivationNonexistent) || handleError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, alp.BasicResponse{
+		Status:  alp.StatusDeleted,
+		Message: "activation deleted",
+		Code:    http.StatusOK,
+	})
+}
+
+func pathID(c *gin.Context, param string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil || id < 1 {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid "+param)
+		return 0, false
+	}
+	return id, true
+}
+
+// loadLicense fetches the license named by the id parameter, responding
+// with an error when it doesn't exist or the caller may not see it.
+func loadLicense(c *gin.Context) (alp.License, bool) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return alp.License{}, false
+	}
+	lic, err := database.GetLicenseByID(db, getTenant(c).Id, id)
+	if handleLicenseError(c, err) || !allowProduct(c, lic.Product) {
+		return alp.License{}, false
+	}
+	return lic, true
+}
+
+// applyPatch returns lic with patch's fields applied, other than
+// suspended, and the names of those that changed.
+func applyPatch(c *gin.Context, lic alp.License, patch alp.LicensePatch) (alp.License, []string, bool) {
+	var changed []string
+	if patch.Email != nil && *patch.Email != lic.Email {
+		if *patch.Email == "" {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid email")
+			return lic, nil, false
+		}
+		lic.Email = *patch.Email
+		changed = append(changed, "email")
+	}
+	if patch.MaxVersion != nil && *patch.MaxVersion != lic.MaxVersion {
+		if *patch.MaxVersion < 0 {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid version range")
+			return lic, nil, false
+		}
+		lic.MaxVersion = *patch.MaxVersion
+		changed = append(changed, "max_version")
+	}
+	if patch.UpdatesUntil != nil {
+		updatesUntil, err := alp.ParseDate(*patch.UpdatesUntil)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid version range")
+			return lic, nil, false
+		}
+		if !sameTime(updatesUntil, lic.UpdatesUntil) {
+			lic.UpdatesUntil = updatesUntil
+			changed = append(changed, "updates_until")
+		}
+	}
+	if patch.ExpiresAt != nil {
+		expiresAt, err := alp.ParseDate(*patch.ExpiresAt)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid expiry date")
+			return lic, nil, false
+		}
+		if !sameTime(expiresAt, lic.ExpiresAt) {
+			lic.ExpiresAt = expiresAt
+			changed = append(changed, "expires_at")
+		}
+	}
+	if patch.Entitlements != nil && strings.Join(*patch.Entitlements, ",") != strings.Join(lic.Entitlements, ",") {
+		if !validEntitlements(c, *patch.Entitlements) {
+			return lic, nil, false
+		}
+		lic.Entitlements = *patch.Entitlements
+		changed = append(changed, "entitlements")
+	}
+	if patch.MaxActivations != nil && *patch.MaxActivations != lic.MaxActivations {
+		if *patch.MaxActivations < 0 {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid activation limit")
+			return lic, nil, false
+		}
+		lic.MaxActivations = *patch.MaxActivations
+		changed = append(changed, "max_activations")
+	}
+	return lic, changed, true
+}
+
+// updateLicense saves lic, bumping its revision, unless it changed since it
+// was read.
+func updateLicense(c *gin.Context, lic alp.License, action, details string) bool {
+	err := database.UpdateLicense(db, getTenant(c).Id, lic, action, getPrincipal(c).Actor(), details)
+	if errors.Is(err, database.ErrLicenseModified) {
+		respondError(c, http.StatusPreconditionFailed, alp.ErrorPreconditionFailed, err.Error())
+		return false
+	}
+	return !handleError(c, err)
+}
+
+func etag(lic alp.License) string {
+	return fmt.Sprintf(`"%d-%d"`, lic.Id, lic.Revision)
+}
+
+func etagListed(header string, lic alp.License) bool {
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag(lic) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch checks the request's If-Match header, when it has one, against
+// lic. Without one, changes are made regardless.
+func ifMatch(c *gin.Context, lic alp.License) bool {
+	if header := c.GetHeader("If-Match"); header != "" && !etagListed(header, lic) {
+		respondError(c, http.StatusPreconditionFailed, alp.ErrorPreconditionFailed, database.ErrLicenseModified.Error())
+		return false
+	}
+	return true
+}
+
+// showLicense returns lic as clients see it, with its key encrypted.
+func showLicense(c *gin.Context, lic alp.License) (alp.License, bool) {
+	key, err := utils.EncryptLicense(getTenant(c), lic.LicenseKey)
+	if handleError(c, err) {
+		return alp.License{}, false
+	}
+	lic.LicenseKey = key
+	return lic, true
+}
+
+func respondLicense(c *gin.Context, status int, lic alp.License) {
+	lic.Code = status
+	c.Header("ETag", etag(lic))
+	c.JSON(status, lic)
+}
+
+func licenseData(c *gin.Context, lic alp.License) webhooks.LicenseData {
+	return webhooks.LicenseData{
+		Key:       lic.LicenseKey,
+		Product:   lic.Product,
+		Email:     lic.Email,
+		ExpiresAt: lic.ExpiresAt,
+		Actor:     getPrincipal(c).Actor(),
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/alp/openapi"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func OpenAPIRouter(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openapi.JSON())
+}
+
+// ValidateRequest rejects requests that don't match the OpenAPI document
+// before they reach a handler.
+func ValidateRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params := map[string]string{}
+		for _, param := range c.Params {
+			params[param.Key] = param.Value
+		}
+
+		if err := openapi.ValidateRequest(c.Request, c.FullPath(), params); err != nil {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid request: "+err.Error())
+			return
+		}
+		c.Next()
+	}
+}
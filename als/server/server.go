@@ -2,15 +2,22 @@ package server
 
 import (
 	"database/sql"
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/auth"
 	"github.com/GreatGodApollo/als/crypto"
 	"github.com/GreatGodApollo/als/database"
 	"github.com/GreatGodApollo/als/models"
 	"github.com/GreatGodApollo/als/utils"
+	"github.com/GreatGodApollo/als/webhooks"
 	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
 	"github.com/spf13/viper"
-	limit "github.com/yangxikun/gin-limit-by-key"
 	"golang.org/x/time/rate"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,49 +31,88 @@ func RunAPI() {
 	if viper.GetBool("server.production") {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	Router().Run(viper.GetString("server.bind"))
+}
+
+// Router returns the engine serving every route of the API.
+func Router() *gin.Engine {
 	r := gin.Default()
 
+	r.Use(ResolveTenant())
+
 	r.GET("/", IndexRouter)
+	r.GET("/openapi.json", OpenAPIRouter)
 
 	api := r.Group("/api")
 	{
 		v1 := api.Group("/v1")
 		{
-			auth := v1.Group("/", gin.BasicAuth(viper.GetStringMapString("auth.accounts")))
+			admin := v1.Group("/", Authenticate(), ValidateRequest())
 			{
-				auth.POST("/create", CreateRouter)
-				auth.POST("/invalidate", InvalidateRouter)
-				auth.POST("/specific", GetRouter)
-				auth.GET("/all/:product", GetAllRouter)
+				admin.POST("/create", RequireScope(auth.ScopeLicensesWrite), CreateRouter)
+				admin.POST("/invalidate", RequireScope(auth.ScopeLicensesInvalidate), InvalidateRouter)
+				admin.POST("/suspend", RequireScope(auth.ScopeLicensesInvalidate), SuspendRouter)
+				admin.POST("/resume", RequireScope(auth.ScopeLicensesInvalidate), ResumeRouter)
+				admin.POST("/specific", RequireScope(auth.ScopeLicensesRead), GetRouter)
+				admin.POST("/history", RequireScope(auth.ScopeLicensesRead), HistoryRouter)
+				admin.GET("/all/:product", RequireScope(auth.ScopeLicensesRead), GetAllRouter)
+				admin.GET("/products", RequireScope(auth.ScopeLicensesRead), GetProductsRouter)
+
+				keys := admin.Group("/keys", RequireScope(auth.ScopeKeysAdmin))
+				{
+					keys.POST("/create", CreateKeyRouter)
+					keys.POST("/revoke", RevokeKeyRouter)
+					keys.GET("/all", GetKeysRouter)
+				}
+
+				hooks := admin.Group("/webhooks", RequireScope(auth.ScopeWebhooksAdmin))
+				{
+					hooks.POST("/create", CreateWebhookRouter)
+					hooks.POST("/delete", DeleteWebhookRouter)
+					hooks.GET("/all", GetWebhooksRouter)
+					hooks.GET("/dead", GetDeadDeliveriesRouter)
+					hooks.POST("/replay", ReplayDeliveryRouter)
+				}
 			}
 		}
+
+		v2 := api.Group("/v2", Authenticate(), ValidateRequest(), RejectDryRun())
+		{
+			read := RequireScope(auth.ScopeLicensesRead)
+			write := RequireScope(auth.ScopeLicensesWrite)
+
+			v2.GET("/licenses", read, ListLicensesRouter)
+			v2.POST("/licenses", write, PostLicenseRouter)
+			v2.GET("/licenses/:id", read, GetLicenseRouter)
+			v2.PATCH("/licenses/:id", write, PatchLicenseRouter)
+			v2.DELETE("/licenses/:id", RequireScope(auth.ScopeLicensesInvalidate), DeleteLicenseRouter)
+			v2.GET("/licenses/:id/history", read, GetLicenseHistoryRouter)
+			v2.GET("/licenses/:id/activations", read, GetActivationsRouter)
+			v2.POST("/licenses/:id/activations", write, PostActivationRouter)
+			v2.DELETE("/licenses/:id/activations/:activation", write, DeleteActivationRouter)
+		}
 	}
 
-	license := r.Group("/license")
+	// Limiters are kept for an hour, so the limit is part of the key for a
+	// changed one to apply straight away.
+	license := r.Group("/license", RateLimit(func(c *gin.Context) string {
+		tenant := getTenant(c)
+		return strconv.Itoa(tenant.Id) + ":" + strconv.Itoa(tenant.RateLimit) + ":" + c.ClientIP()
+	}, func(c *gin.Context) int {
+		return getTenant(c).RateLimit
+	}))
+	license.Use(ValidateRequest())
 	{
 		license.POST("/check", CheckRouter)
+		license.GET("/pubkey", PublicKeyRouter)
 	}
 
-	license.Use(limit.NewRateLimiter(func(c *gin.Context) string {
-		return c.ClientIP()
-	}, func(c *gin.Context) (*rate.Limiter, time.Duration) {
-		return rate.NewLimiter(rate.Every(1*time.Minute), 10), time.Hour
-	}, func(c *gin.Context) {
-		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-			"message": "you have reached your limit!",
-			"code":    http.StatusTooManyRequests,
-		})
-	}))
-
 	r.NoRoute(NotFoundRouter)
-
-	r.Run(viper.GetString("server.bind"))
+	return r
 }
 
 func NotFoundRouter(c *gin.Context) {
-	c.JSON(http.StatusNotFound, gin.H{
-		"message": "404: not found",
-	})
+	respondError(c, http.StatusNotFound, alp.ErrorNotFound, "404: not found")
 }
 
 func IndexRouter(c *gin.Context) {
@@ -76,103 +122,196 @@ func IndexRouter(c *gin.Context) {
 }
 
 func CreateRouter(c *gin.Context) {
-	var req models.LicenseRequest
+	tenant := getTenant(c)
+	var req alp.LicenseRequest
 
 	if c.ShouldBind(&req) == nil {
-		crypt, err := utils.GenerateEncryptedLicense(db, req.Product, req.Email)
+		updatesUntil, err := alp.ParseDate(req.UpdatesUntil)
+		if err != nil || req.MaxVersion < 0 {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid version range")
+			return
+		}
+		expiresAt, err := alp.ParseDate(req.ExpiresAt)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid expiry date")
+			return
+		}
+		if !validEntitlements(c, req.Entitlements) || !allowProduct(c, req.Product) {
+			return
+		}
+		if dryRun(c) {
+			c.JSON(http.StatusOK, alp.LicenseResponse{
+				Status:  alp.StatusDryRun,
+				Message: "license would be created",
+				Code:    http.StatusOK,
+			})
+			return
+		}
+
+		crypt, err := utils.GenerateEncryptedLicense(db, tenant, alp.License{
+			Product:      req.Product,
+			Email:        req.Email,
+			MaxVersion:   req.MaxVersion,
+			UpdatesUntil: updatesUntil,
+			ExpiresAt:    expiresAt,
+			Entitlements: req.Entitlements,
+		}, getPrincipal(c).Actor())
 		if handleError(c, err) {
 			return
 		}
 
-		c.JSON(http.StatusCreated, models.LicenseResponse{
+		notify(tenant, webhooks.EventLicenseCreated, webhooks.LicenseData{
+			Key:       crypto.EncodeBase64(crypt),
+			Product:   req.Product,
+			Email:     req.Email,
+			ExpiresAt: expiresAt,
+			Actor:     getPrincipal(c).Actor(),
+		})
+
+		c.JSON(http.StatusCreated, alp.LicenseResponse{
 			LicenseKey: crypto.EncodeBase64(crypt),
-			Status:     "created",
+			Status:     alp.StatusCreated,
 			Message:    "license created",
 			Code:       http.StatusCreated,
 		})
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "required parameters were not provided!",
-			"code":    http.StatusBadRequest,
-		})
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
 	}
 }
 
 func InvalidateRouter(c *gin.Context) {
-	var req models.BasicRequest
+	tenant := getTenant(c)
+	var req alp.BasicRequest
 	if c.ShouldBind(&req) == nil {
-		// Decode and decrypt key
-		enc, err := crypto.DecodeBase64(req.Key)
-		if handleError(c, err) {
-			return
-		}
-		key, err := utils.DecryptLicense(enc)
-		if handleError(c, err) {
+		key, ok := decodeKey(c, tenant, req.Key)
+		if !ok {
 			return
 		}
 
-		exist, err := database.CheckLicenseExist(db, string(key))
-		if handleError(c, err) {
+		licObj, err := database.GetWholeRecord(db, tenant.Id, key)
+		if handleLicenseError(c, err) {
 			return
 		}
-
-		exist, valid, err := database.CheckLicenseValid(db, string(key))
-		if handleLicenseError(c, req.Key, err) {
+		if !allowProduct(c, licObj.Product) {
 			return
 		}
 
-		if exist && valid {
-			_, err := database.InvalidateLicense(db, string(key))
+		if licObj.Valid && dryRun(c) {
+			c.JSON(http.StatusOK, alp.LicenseResponse{
+				LicenseKey: req.Key,
+				Status:     alp.StatusDryRun,
+				Message:    "license would be invalidated",
+				Code:       http.StatusOK,
+			})
+		} else if licObj.Valid {
+			_, err := database.InvalidateLicense(db, tenant.Id, key, getPrincipal(c).Actor())
 			if handleError(c, err) {
 				return
 			}
 
-			c.JSON(http.StatusOK, models.LicenseResponse{
-				LicenseKey: req.Key,
-				Status:     "invalidated",
-				Message:    "license invalidated",
-				Code:       http.StatusOK,
+			notify(tenant, webhooks.EventLicenseInvalidated, webhooks.LicenseData{
+				Key:       req.Key,
+				Product:   licObj.Product,
+				Email:     licObj.Email,
+				ExpiresAt: licObj.ExpiresAt,
+				Actor:     getPrincipal(c).Actor(),
 			})
-		} else if exist {
-			c.JSON(http.StatusOK, models.LicenseResponse{
+
+			c.JSON(http.StatusOK, alp.LicenseResponse{
 				LicenseKey: req.Key,
-				Status:     "invalid",
-				Message:    "license already invalid",
+				Status:     alp.StatusInvalidated,
+				Message:    "license invalidated",
 				Code:       http.StatusOK,
 			})
 		} else {
-			c.JSON(http.StatusOK, models.LicenseResponse{
+			c.JSON(http.StatusOK, alp.LicenseResponse{
 				LicenseKey: req.Key,
-				Status:     "invalid",
-				Message:    "license nonexistent",
+				Status:     alp.StatusInvalid,
+				Message:    alp.MessageLicenseAlreadyInvalid,
 				Code:       http.StatusOK,
 			})
 		}
-
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "required parameters not provided",
-			"code":    http.StatusBadRequest,
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+func SuspendRouter(c *gin.Context) {
+	setSuspended(c, true)
+}
+
+func ResumeRouter(c *gin.Context) {
+	setSuspended(c, false)
+}
+
+func setSuspended(c *gin.Context, suspended bool) {
+	tenant := getTenant(c)
+	var req alp.BasicRequest
+	if c.ShouldBind(&req) == nil {
+		key, ok := decodeKey(c, tenant, req.Key)
+		if !ok {
+			return
+		}
+
+		licObj, err := database.GetWholeRecord(db, tenant.Id, key)
+		if handleLicenseError(c, err) {
+			return
+		}
+		if !allowProduct(c, licObj.Product) {
+			return
+		}
+
+		status, message, event := alp.StatusSuspended, "license suspended", webhooks.EventLicenseSuspended
+		if !suspended {
+			status, message, event = alp.StatusResumed, "license resumed", webhooks.EventLicenseResumed
+		}
+
+		switch {
+		case !licObj.Valid:
+			status, message = alp.StatusInvalid, alp.MessageLicenseAlreadyInvalid
+		case licObj.Suspended == suspended:
+			status, message = alp.StatusUnchanged, "license already "+status
+		case dryRun(c):
+			status, message = alp.StatusDryRun, "license would be "+status
+		default:
+			err = database.SuspendLicense(db, tenant.Id, key, getPrincipal(c).Actor(), suspended)
+			if handleError(c, err) {
+				return
+			}
+			notify(tenant, event, webhooks.LicenseData{
+				Key:       req.Key,
+				Product:   licObj.Product,
+				Email:     licObj.Email,
+				ExpiresAt: licObj.ExpiresAt,
+				Actor:     getPrincipal(c).Actor(),
+			})
+		}
+
+		c.JSON(http.StatusOK, alp.LicenseResponse{
+			LicenseKey: req.Key,
+			Status:     status,
+			Message:    message,
+			Code:       http.StatusOK,
 		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
 	}
 }
 
 func GetRouter(c *gin.Context) {
-	var req models.BasicRequest
+	tenant := getTenant(c)
+	var req alp.BasicRequest
 	if c.ShouldBind(&req) == nil {
-		enc, err := crypto.DecodeBase64(req.Key)
-		if handleError(c, err) {
+		key, ok := decodeKey(c, tenant, req.Key)
+		if !ok {
 			return
 		}
-		key, err := utils.DecryptLicense(enc)
-		if handleError(c, err) {
+
+		licObj, err := database.GetWholeRecord(db, tenant.Id, key)
+		if handleLicenseError(c, err) {
 			return
 		}
-
-		licObj, err := database.GetWholeRecord(db, string(key))
-		if handleLicenseError(c, req.Key, err) {
+		if !allowProduct(c, licObj.Product) {
 			return
 		}
 
@@ -181,16 +320,47 @@ func GetRouter(c *gin.Context) {
 
 		c.JSON(http.StatusOK, licObj)
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "required parameters not provided",
-			"code":    http.StatusBadRequest,
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+func HistoryRouter(c *gin.Context) {
+	tenant := getTenant(c)
+	var req alp.BasicRequest
+	if c.ShouldBind(&req) == nil {
+		key, ok := decodeKey(c, tenant, req.Key)
+		if !ok {
+			return
+		}
+
+		licObj, err := database.GetWholeRecord(db, tenant.Id, key)
+		if handleLicenseError(c, err) {
+			return
+		}
+		if !allowProduct(c, licObj.Product) {
+			return
+		}
+
+		history, err := database.GetHistory(db, tenant.Id, key)
+		if handleError(c, err) {
+			return
+		}
+		c.JSON(http.StatusOK, alp.History{
+			LicenseKey: req.Key,
+			History:    history,
+			Code:       http.StatusOK,
 		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
 	}
 }
 
 func GetAllRouter(c *gin.Context) {
-	objects, err := database.GetAllValidRecords(db, c.Param("product"))
+	tenant := getTenant(c)
+	if !allowProduct(c, c.Param("product")) {
+		return
+	}
+	objects, err := database.GetAllValidRecords(db, tenant, c.Param("product"))
 	if handleError(c, err) {
 		return
 	}
@@ -198,92 +368,210 @@ func GetAllRouter(c *gin.Context) {
 	c.JSON(http.StatusOK, objects)
 }
 
+// GetProductsRouter lists the products with licenses that the caller may
+// see.
+func GetProductsRouter(c *gin.Context) {
+	tenant := getTenant(c)
+	products, err := database.GetProducts(db, tenant.Id)
+	if handleError(c, err) {
+		return
+	}
+
+	principal := getPrincipal(c)
+	resp := alp.Products{Code: http.StatusOK, Products: []string{}}
+	for _, product := range products {
+		if principal.CanProduct(product) {
+			resp.Products = append(resp.Products, product)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 func CheckRouter(c *gin.Context) {
-	var req models.CheckRequest
+	tenant := getTenant(c)
+	var req alp.CheckRequest
 
 	if c.ShouldBind(&req) == nil {
 
-		// Decode & Decrypt Key
-		enc, err := crypto.DecodeBase64(req.Key)
-		if handleError(c, err) {
-			return
-		}
-		key, err := utils.DecryptLicense(enc)
-		if handleError(c, err) {
+		key, ok := decodeKey(c, tenant, req.Key)
+		if !ok {
 			return
 		}
 
 		// Check if key exists in DB
-		exist, err := database.CheckLicenseExist(db, string(key))
+		exist, err := database.CheckLicenseExist(db, tenant.Id, key)
 		if handleError(c, err) {
 			return
 		}
 
 		// Check if valid
-		exist, valid, err := database.CheckLicenseValidProduct(db, string(key), req.Product)
-		if handleLicenseError(c, req.Key, err) {
+		exist, valid, err := database.CheckLicenseValidProduct(db, tenant.Id, key, req.Product)
+		if errors.Is(err, database.ErrIncorrectProduct) {
+			respondCheck(c, req, http.StatusOK, alp.LicenseResponse{
+				LicenseKey: req.Key,
+				Status:     alp.StatusInvalid,
+				Message:    alp.MessageIncorrectProduct,
+				Code:       http.StatusOK,
+				Error:      alp.ErrorProductMismatch,
+			})
+			return
+		}
+		if handleError(c, err) {
 			return
 		}
 
+		var licObj alp.License
 		if exist && valid {
-			c.JSON(http.StatusOK, models.LicenseResponse{
-				LicenseKey: req.Key,
-				Status:     "valid",
-				Message:    "license valid",
-				Code:       http.StatusOK,
+			licObj, err = database.GetWholeRecord(db, tenant.Id, key)
+			if handleError(c, err) {
+				return
+			}
+
+			if licObj.Suspended {
+				respondCheck(c, req, http.StatusOK, alp.LicenseResponse{
+					LicenseKey: req.Key,
+					Status:     alp.StatusSuspended,
+					Message:    "license suspended",
+					Code:       http.StatusOK,
+				})
+				return
+			}
+
+			if licObj.ExpiresAt != nil && licObj.ExpiresAt.Before(time.Now()) {
+				respondCheck(c, req, http.StatusOK, alp.LicenseResponse{
+					LicenseKey: req.Key,
+					Status:     alp.StatusExpired,
+					Message:    "license expired",
+					Code:       http.StatusOK,
+				})
+				return
+			}
+
+			covered, err := alp.VersionCovered(licObj, req.Version, req.ReleaseDate)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, err.Error())
+				return
+			}
+			if !covered {
+				respondCheck(c, req, http.StatusOK, alp.LicenseResponse{
+					LicenseKey: req.Key,
+					Status:     alp.StatusVersionNotCovered,
+					Message:    "license does not cover this version",
+					Code:       http.StatusOK,
+				})
+				return
+			}
+		}
+
+		if exist && valid {
+			respondCheck(c, req, http.StatusOK, alp.LicenseResponse{
+				LicenseKey:   req.Key,
+				Status:       alp.StatusValid,
+				Message:      "license valid",
+				Code:         http.StatusOK,
+				ExpiresAt:    licObj.ExpiresAt,
+				Entitlements: licObj.Entitlements,
 			})
 		} else if exist {
-			c.JSON(http.StatusOK, models.LicenseResponse{
+			respondCheck(c, req, http.StatusOK, alp.LicenseResponse{
 				LicenseKey: req.Key,
-				Status:     "invalid",
+				Status:     alp.StatusInvalid,
 				Message:    "license invalid",
 				Code:       http.StatusOK,
 			})
 		} else {
-			c.JSON(http.StatusNotFound, models.LicenseResponse{
+			respondCheck(c, req, http.StatusNotFound, alp.LicenseResponse{
 				LicenseKey: req.Key,
-				Status:     "invalid",
-				Message:    "license nonexistent",
+				Status:     alp.StatusInvalid,
+				Message:    alp.MessageLicenseNonexistent,
 				Code:       http.StatusNotFound,
+				Error:      alp.ErrorLicenseNotFound,
 			})
 		}
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "required parameters not provided",
-			"code":    http.StatusBadRequest,
-		})
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
 	}
 }
 
-func handleLicenseError(c *gin.Context, license string, err error) bool {
-	if err != nil {
-		if err.Error() == "incorrect product" || err.Error() == "license nonexistent" {
-			c.JSON(http.StatusOK, models.LicenseResponse{
-				LicenseKey: license,
-				Status:     "invalid",
-				Message:    err.Error(),
-				Code:       http.StatusOK,
-			})
-			return true
+// validEntitlements responds with an error unless every entitlement can be
+// stored.
+func validEntitlements(c *gin.Context, entitlements []string) bool {
+	for _, entitlement := range entitlements {
+		if entitlement == "" || strings.Contains(entitlement, ",") {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid entitlement")
+			return false
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": err.Error(),
-			"code":    http.StatusInternalServerError,
-		})
-		return true
 	}
-	return false
+	return true
 }
 
-func handleError(c *gin.Context, err error) bool {
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": err.Error(),
-			"code":    http.StatusInternalServerError,
-		})
+// RateLimit allows perMinute requests a minute for each key, in bursts of
+// up to perMinute. Beyond that it answers 429 with Retry-After set to when
+// the next request will be allowed.
+func RateLimit(key func(*gin.Context) string, perMinute func(*gin.Context) int) gin.HandlerFunc {
+	limiters := cache.New(5*time.Minute, 10*time.Minute)
+	return func(c *gin.Context) {
+		k := key(c)
+		limiter, ok := limiters.Get(k)
+		if !ok {
+			n := perMinute(c)
+			if n < 1 {
+				n = 1
+			}
+			limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(n)), n)
+			limiters.Set(k, limiter, time.Hour)
+		}
+
+		reservation := limiter.(*rate.Limiter).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			respondError(c, http.StatusTooManyRequests, alp.ErrorRateLimited, "you have reached your limit!")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectDryRun refuses changes asked to be dry runs, for routes that can't
+// do them, so they aren't carried out by mistake.
+func RejectDryRun() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && dryRun(c) {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "dry_run is not supported here")
+			return
+		}
+		c.Next()
+	}
+}
+
+// dryRun reports whether the request asked, with ?dry_run=true, to be
+// validated without changing anything.
+func dryRun(c *gin.Context) bool {
+	b, _ := strconv.ParseBool(c.Query("dry_run"))
+	return b
+}
+
+// handleLicenseError is handleError for lookups of a license the caller
+// named, which may not exist.
+func handleLicenseError(c *gin.Context, err error) bool {
+	if errors.Is(err, database.ErrLicenseNonexistent) {
+		respondError(c, http.StatusNotFound, alp.ErrorLicenseNotFound, alp.MessageLicenseNonexistent)
 		return true
 	}
-	return false
+	return handleError(c, err)
+}
+
+// decodeKey turns a license key as clients see it back into the one stored,
+// responding with an error when it can't be.
+func decodeKey(c *gin.Context, tenant models.Tenant, key string) (string, bool) {
+	enc, err := crypto.DecodeBase64(key)
+	if err == nil {
+		var dec []byte
+		if dec, err = utils.DecryptLicense(tenant, enc); err == nil {
+			return string(dec), true
+		}
+	}
+	respondError(c, http.StatusBadRequest, alp.ErrorInvalidKeyFormat, "invalid license key")
+	return "", false
 }
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/GreatGodApollo/alp/openapi"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRoutesDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := Router().Routes()
+	if len(routes) == 0 {
+		t.Fatal("router has no routes")
+	}
+	for _, route := range routes {
+		if !openapi.Documented(route.Method, route.Path) {
+			t.Errorf("%s %s is not in the OpenAPI document", route.Method, route.Path)
+		}
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", RateLimit(func(*gin.Context) string { return "client" }, func(*gin.Context) int { return 2 }), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := get(); w.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst: %d", i+1, w.Code)
+		}
+	}
+	w := get()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: %d", w.Code)
+	}
+	// Two a minute frees a request every 30 seconds.
+	if after, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || after < 29 || after > 30 {
+		t.Errorf("Retry-After = %q, want 30", w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/crypto"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// respondCheck signs a check response with the tenant's key, echoing the
+// client's nonce, so clients can tell it came from this server.
+func respondCheck(c *gin.Context, req alp.CheckRequest, status int, resp alp.LicenseResponse) {
+	resp.Product = req.Product
+	resp.Version = req.Version
+	resp.ReleaseDate = req.ReleaseDate
+	resp.Nonce = req.Nonce
+	resp.Timestamp = time.Now().Unix()
+
+	sig, err := crypto.Sign(getTenant(c).SignKey, resp.SigningPayload())
+	if handleError(c, err) {
+		return
+	}
+	resp.Signature = sig
+	c.JSON(status, resp)
+}
+
+func PublicKeyRouter(c *gin.Context) {
+	key, err := crypto.PublicKey(getTenant(c).SignKey)
+	if handleError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "ed25519",
+		"public_key": key,
+		"code":       http.StatusOK,
+	})
+}
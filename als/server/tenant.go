@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/models"
+	"github.com/gin-gonic/gin"
+	"net"
+)
+
+const (
+	tenantKey     = "tenant"
+	tenantHostKey = "tenant_by_host"
+)
+
+// ResolveTenant picks the tenant from the request's host, falling back to
+// the default tenant. Authenticated routes may narrow it further based on
+// the credential used.
+func ResolveTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		tenant, err := database.GetTenantByHost(db, host)
+		if err == nil {
+			c.Set(tenantHostKey, true)
+		} else if errors.Is(err, database.ErrTenantNonexistent) {
+			tenant, err = database.GetTenant(db, database.DefaultTenantId)
+		}
+		if handleError(c, err) {
+			c.Abort()
+			return
+		}
+
+		c.Set(tenantKey, tenant)
+		c.Next()
+	}
+}
+
+func getTenant(c *gin.Context) models.Tenant {
+	return c.MustGet(tenantKey).(models.Tenant)
+}
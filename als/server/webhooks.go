@@ -0,0 +1,153 @@
+package server
+
+import (
+	"errors"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/models"
+	"github.com/GreatGodApollo/als/webhooks"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// notify queues a webhook event. Failing to do so must not fail the request
+// that caused it, so errors are only logged.
+func notify(tenant models.Tenant, event string, data interface{}) {
+	if err := webhooks.Enqueue(db, tenant.Id, event, data); err != nil {
+		log.Println("webhooks: could not enqueue", event+":", err.Error())
+	}
+}
+
+func CreateWebhookRouter(c *gin.Context) {
+	var req alp.WebhookRequest
+
+	if c.ShouldBind(&req) == nil {
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "invalid webhook url")
+			return
+		}
+		for _, event := range req.Events {
+			if !webhooks.ValidEvent(event) {
+				respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, "unknown event "+event)
+				return
+			}
+		}
+
+		if dryRun(c) {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  alp.StatusDryRun,
+				"message": "webhook would be created",
+				"code":    http.StatusOK,
+			})
+			return
+		}
+
+		secret, err := webhooks.GenerateSecret()
+		if handleError(c, err) {
+			return
+		}
+		hook := alp.Webhook{
+			URL:       req.URL,
+			Secret:    secret,
+			Events:    req.Events,
+			Active:    true,
+			CreatedAt: time.Now(),
+		}
+		hook.Id, err = database.InsertWebhook(db, getTenant(c).Id, hook)
+		if handleError(c, err) {
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"webhook": hook,
+			"status":  alp.StatusCreated,
+			"message": "webhook created",
+			"code":    http.StatusCreated,
+		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+func GetWebhooksRouter(c *gin.Context) {
+	hooks, err := database.GetAllWebhooks(db, getTenant(c).Id)
+	if handleError(c, err) {
+		return
+	}
+	for i := range hooks.Webhooks {
+		hooks.Webhooks[i].Secret = ""
+	}
+	hooks.Code = http.StatusOK
+	c.JSON(http.StatusOK, hooks)
+}
+
+func DeleteWebhookRouter(c *gin.Context) {
+	var req alp.RevokeRequest
+	if c.ShouldBind(&req) == nil {
+		if dryRun(c) {
+			_, err := database.GetWebhook(db, getTenant(c).Id, req.Id)
+			if handleNotFound(c, err, database.ErrWebhookNonexistent) || handleError(c, err) {
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"status":  alp.StatusDryRun,
+				"message": "webhook would be deleted",
+				"code":    http.StatusOK,
+			})
+			return
+		}
+
+		err := database.DeleteWebhook(db, getTenant(c).Id, req.Id)
+		if handleNotFound(c, err, database.ErrWebhookNonexistent) || handleError(c, err) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":  alp.StatusDeleted,
+			"message": "webhook deleted",
+			"code":    http.StatusOK,
+		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+func GetDeadDeliveriesRouter(c *gin.Context) {
+	deliveries, err := database.GetDeadDeliveries(db, getTenant(c).Id)
+	if handleError(c, err) {
+		return
+	}
+	deliveries.Code = http.StatusOK
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func ReplayDeliveryRouter(c *gin.Context) {
+	var req alp.RevokeRequest
+	if c.ShouldBind(&req) == nil {
+		err := database.ReplayDelivery(db, getTenant(c).Id, req.Id)
+		if errors.Is(err, database.ErrDeliveryNotFailed) {
+			respondError(c, http.StatusConflict, alp.ErrorConflict, err.Error())
+			return
+		}
+		if handleNotFound(c, err, database.ErrDeliveryNonexistent) || handleError(c, err) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "queued",
+			"message": "delivery queued for replay",
+			"code":    http.StatusOK,
+		})
+	} else {
+		respondError(c, http.StatusBadRequest, alp.ErrorInvalidRequest, alp.MessageRequiredParameters)
+	}
+}
+
+func handleNotFound(c *gin.Context, err error, target error) bool {
+	if errors.Is(err, target) {
+		respondError(c, http.StatusNotFound, alp.ErrorNotFound, target.Error())
+		return true
+	}
+	return false
+}
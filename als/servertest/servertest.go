@@ -0,0 +1,143 @@
+// Package servertest serves the real als API from a scratch MySQL database,
+// for tests that need the actual handlers rather than ala's licensetest.
+package servertest
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"fmt"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/crypto"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/server"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DSNVariable names the environment variable tests read the DSN of a MySQL
+// server they may create databases on from, like root:pass@tcp(db:3306)/.
+const DSNVariable = "ALS_TEST_DSN"
+
+// Server is the API backed by a database of its own. The server package
+// keeps its database in a global, so only one Server may run at a time.
+type Server struct {
+	*httptest.Server
+	DB *sql.DB
+	// Username and Password log in as an owner of the default tenant.
+	Username string
+	Password string
+	// PublicKey verifies the default tenant's check responses.
+	PublicKey ed25519.PublicKey
+
+	admin *sql.DB
+	name  string
+}
+
+// NewServer creates a database from database.sql on the MySQL server at dsn
+// and serves the API from it.
+func NewServer(dsn string) (*Server, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Username: "owner", Password: "owner-password", name: fmt.Sprintf("als_test_%d", time.Now().UnixNano())}
+
+	cfg.DBName = ""
+	if s.admin, err = sql.Open("mysql", cfg.FormatDSN()); err != nil {
+		return nil, err
+	}
+	if _, err = s.admin.Exec("create database " + s.name); err != nil {
+		s.admin.Close()
+		return nil, err
+	}
+
+	cfg.DBName = s.name
+	cfg.ParseTime = true
+	if s.DB, err = sql.Open("mysql", cfg.FormatDSN()); err != nil {
+		s.drop()
+		return nil, err
+	}
+	if err = s.setup(); err != nil {
+		s.drop()
+		return nil, err
+	}
+
+	gin.SetMode(gin.TestMode)
+	server.Setup(s.DB)
+	s.Server = httptest.NewServer(server.Router())
+	return s, nil
+}
+
+func (s *Server) setup() error {
+	schema, err := ioutil.ReadFile(schemaPath())
+	if err != nil {
+		return err
+	}
+	for _, statement := range strings.Split(string(schema), ";") {
+		if strings.TrimSpace(stripComments(statement)) == "" {
+			continue
+		}
+		if _, err = s.DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	cryptKey, err := crypto.GenerateCryptKey()
+	if err != nil {
+		return err
+	}
+	signKey, err := crypto.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	if err = database.EnsureDefaultTenant(s.DB, cryptKey, signKey); err != nil {
+		return err
+	}
+	priv, err := crypto.ParseSigningKey(signKey)
+	if err != nil {
+		return err
+	}
+	s.PublicKey = priv.Public().(ed25519.PublicKey)
+
+	hash, err := auth.HashPassword(s.Password)
+	if err != nil {
+		return err
+	}
+	return database.InsertUser(s.DB, database.DefaultTenantId, s.Username, hash, auth.RoleOwner)
+}
+
+// Close stops the server and drops its database.
+func (s *Server) Close() {
+	s.Server.Close()
+	s.drop()
+}
+
+func (s *Server) drop() {
+	if s.DB != nil {
+		s.DB.Close()
+	}
+	s.admin.Exec("drop database " + s.name)
+	s.admin.Close()
+}
+
+// schemaPath finds database.sql next to this package's source.
+func schemaPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "database.sql")
+}
+
+func stripComments(statement string) string {
+	var lines []string
+	for _, line := range strings.Split(statement, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
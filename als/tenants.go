@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/crypto"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/models"
+	"github.com/GreatGodApollo/als/utils"
+	"strconv"
+)
+
+const tenantsUsage = `usage: als tenants <command>
+
+commands:
+  list                          List tenants
+  add <slug> <name> [host]      Add a tenant and print a bootstrap api key for it
+  host <slug> [host]            Set or clear the host a tenant is resolved by
+  rate-limit <slug> <per-min>   Set a tenant's license check rate limit`
+
+func runTenants(db *sql.DB, args []string) int {
+	var err error
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		err = listTenants(db)
+	case (len(args) == 3 || len(args) == 4) && args[0] == "add":
+		host := ""
+		if len(args) == 4 {
+			host = args[3]
+		}
+		err = addTenant(db, args[1], args[2], host)
+	case (len(args) == 2 || len(args) == 3) && args[0] == "host":
+		var tenant models.Tenant
+		tenant, err = database.GetTenantBySlug(db, args[1])
+		if err == nil {
+			host := ""
+			if len(args) == 3 {
+				host = args[2]
+			}
+			err = database.UpdateTenantHost(db, tenant.Id, host)
+		}
+	case len(args) == 3 && args[0] == "rate-limit":
+		var tenant models.Tenant
+		tenant, err = database.GetTenantBySlug(db, args[1])
+		if err == nil {
+			var perMinute int
+			perMinute, err = strconv.Atoi(args[2])
+			if err == nil {
+				err = database.UpdateTenantRateLimit(db, tenant.Id, perMinute)
+			}
+		}
+	default:
+		fmt.Println(tenantsUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func listTenants(db *sql.DB) error {
+	tenants, err := database.GetAllTenants(db)
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		fmt.Printf("%s\t%s\t%s\t%d/min\n", tenant.Slug, tenant.Name, tenant.Host, tenant.RateLimit)
+	}
+	return nil
+}
+
+func addTenant(db *sql.DB, slug, name, host string) error {
+	signKey, err := crypto.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	cryptKey, err := crypto.GenerateCryptKey()
+	if err != nil {
+		return err
+	}
+	id, err := database.InsertTenant(db, models.Tenant{
+		Slug:      slug,
+		Name:      name,
+		Host:      host,
+		CryptKey:  cryptKey,
+		SignKey:   signKey,
+		RateLimit: 10,
+	})
+	if err != nil {
+		return err
+	}
+
+	key, _, err := utils.MintAPIKey(db, id, alp.APIKey{
+		Name:   "bootstrap",
+		Scopes: auth.AllScopes,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Created tenant", slug)
+	fmt.Println("Bootstrap api key, it will not be shown again:", key)
+	return nil
+}
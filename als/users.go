@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/database"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"strings"
+)
+
+const usersUsage = `usage: als users [--tenant <slug>] <command>
+
+commands:
+  list                        List admin users
+  add <username> <role>       Add a user, the password is read from the terminal
+  passwd <username>           Change a user's password
+  role <username> <role>      Change a user's role
+  disable <username>          Prevent a user from logging in
+  enable <username>           Allow a disabled user to log in again
+  remove <username>           Delete a user
+
+roles: viewer, support, issuer, owner`
+
+func runUsers(db *sql.DB, args []string) int {
+	tenantSlug := "default"
+	if len(args) > 1 && args[0] == "--tenant" {
+		tenantSlug = args[1]
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		fmt.Println(usersUsage)
+		return 2
+	}
+
+	tenant, err := database.GetTenantBySlug(db, tenantSlug)
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return 1
+	}
+	tenantID := tenant.Id
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		err = listUsers(db, tenantID)
+	case args[0] == "add" && len(args) == 3:
+		err = addUser(db, tenantID, args[1], args[2])
+	case args[0] == "passwd" && len(args) == 2:
+		var hash string
+		hash, err = readPasswordHash()
+		if err == nil {
+			err = database.UpdateUserPassword(db, tenantID, args[1], hash)
+		}
+	case args[0] == "role" && len(args) == 3:
+		if !auth.ValidRole(args[2]) {
+			err = errors.New("unknown role " + args[2])
+		} else {
+			err = database.UpdateUserRole(db, tenantID, args[1], args[2])
+		}
+	case args[0] == "disable" && len(args) == 2:
+		err = database.SetUserDisabled(db, tenantID, args[1], true)
+	case args[0] == "enable" && len(args) == 2:
+		err = database.SetUserDisabled(db, tenantID, args[1], false)
+	case args[0] == "remove" && len(args) == 2:
+		err = database.DeleteUser(db, tenantID, args[1])
+	default:
+		fmt.Println(usersUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func listUsers(db *sql.DB, tenantID int) error {
+	users, err := database.GetAllUsers(db, tenantID)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		fmt.Println("No users found!")
+		return nil
+	}
+	for _, user := range users {
+		status := ""
+		if user.Disabled {
+			status = " (disabled)"
+		}
+		fmt.Printf("%s\t%s%s\n", user.Username, user.Role, status)
+	}
+	return nil
+}
+
+func addUser(db *sql.DB, tenantID int, username, role string) error {
+	if !auth.ValidRole(role) {
+		return errors.New("unknown role " + role)
+	}
+	hash, err := readPasswordHash()
+	if err != nil {
+		return err
+	}
+	return database.InsertUser(db, tenantID, username, hash, role)
+}
+
+// readPasswordHash prompts for a password when attached to a terminal and
+// otherwise reads a single line from stdin, so it can be scripted.
+func readPasswordHash() (string, error) {
+	var password string
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		fmt.Print("Password: ")
+		first, err := terminal.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		fmt.Print("Confirm password: ")
+		second, err := terminal.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passwords do not match")
+		}
+		password = string(first)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters")
+	}
+	return auth.HashPassword(password)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"github.com/GreatGodApollo/als/database"
+	"github.com/GreatGodApollo/als/webhooks"
+	"log"
+	"time"
+)
+
+// WatchExpiry announces expired licenses every interval until ctx is done.
+func WatchExpiry(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := NotifyExpired(db); err != nil {
+			log.Println("expiry:", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NotifyExpired sends a license.expired event for every license that has
+// expired since the last run.
+func NotifyExpired(db *sql.DB) error {
+	licenses, tenantIDs, err := database.GetNewlyExpired(db, 100)
+	if err != nil {
+		return err
+	}
+
+	for i, lic := range licenses {
+		tenant, err := database.GetTenant(db, tenantIDs[i])
+		if err != nil {
+			return err
+		}
+		key, err := EncryptLicense(tenant, lic.LicenseKey)
+		if err != nil {
+			return err
+		}
+
+		err = webhooks.Enqueue(db, tenant.Id, webhooks.EventLicenseExpired, webhooks.LicenseData{
+			Key:       key,
+			Product:   lic.Product,
+			Email:     lic.Email,
+			ExpiresAt: lic.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err = database.MarkExpiryNotified(db, tenant.Id, lic.LicenseKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
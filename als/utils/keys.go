@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"database/sql"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/auth"
+	"github.com/GreatGodApollo/als/database"
+	"time"
+)
+
+// MintAPIKey stores a new API key for the tenant and returns the plaintext
+// key, which is never stored.
+func MintAPIKey(db *sql.DB, tenantID int, apiKey alp.APIKey) (string, alp.APIKey, error) {
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return "", alp.APIKey{}, err
+	}
+
+	apiKey.TenantId = tenantID
+	apiKey.Prefix = prefix
+	apiKey.CreatedAt = time.Now()
+	apiKey.Id, err = database.InsertAPIKey(db, tenantID, apiKey, hash)
+	if err != nil {
+		return "", alp.APIKey{}, err
+	}
+	return key, apiKey, nil
+}
@@ -3,9 +3,10 @@ package utils
 import (
 	"database/sql"
 	"errors"
+	"github.com/GreatGodApollo/alp"
 	"github.com/GreatGodApollo/als/crypto"
 	"github.com/GreatGodApollo/als/database"
-	"github.com/spf13/viper"
+	"github.com/GreatGodApollo/als/models"
 	"math/rand"
 	"time"
 )
@@ -29,28 +30,36 @@ func generateLicenseString() string {
 	return RandomString(4) + "-" + RandomString(4) + "-" + RandomString(4)
 }
 
-func GenerateEncryptedLicense(db *sql.DB, product, email string) ([]byte, error) {
+func GenerateEncryptedLicense(db *sql.DB, tenant models.Tenant, lic alp.License, actor string) ([]byte, error) {
 	key := generateLicenseString()
 
-	exist, err := database.CheckLicenseExist(db, key)
+	exist, err := database.CheckLicenseExist(db, tenant.Id, key)
 	if err != nil {
 		return nil, err
 	}
 	if !exist {
-		query, err := db.Prepare("insert licenses SET license_key=?, product=?, email=?")
+		lic.LicenseKey = key
+		err = database.InsertLicense(db, tenant.Id, lic)
 		if err != nil {
 			return nil, err
 		}
-		_, err = query.Exec(key, product, email)
+		err = database.RecordHistory(db, tenant.Id, key, database.HistoryCreated, actor, "product "+lic.Product+" for "+lic.Email)
 		if err != nil {
 			return nil, err
 		}
-		defer query.Close()
-		return crypto.Encrypt([]byte(viper.GetString("crypt.key")), []byte(key))
+		return crypto.Encrypt([]byte(tenant.CryptKey), []byte(key))
 	}
 	return nil, errors.New("license already exists")
 }
 
-func DecryptLicense(encrypted []byte) ([]byte, error) {
-	return crypto.Decrypt([]byte(viper.GetString("crypt.key")), encrypted)
+func DecryptLicense(tenant models.Tenant, encrypted []byte) ([]byte, error) {
+	return crypto.Decrypt([]byte(tenant.CryptKey), encrypted)
+}
+
+func EncryptLicense(tenant models.Tenant, key string) (string, error) {
+	enc, err := crypto.Encrypt([]byte(tenant.CryptKey), []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return crypto.EncodeBase64(enc), nil
 }
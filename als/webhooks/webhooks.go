@@ -0,0 +1,232 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GreatGodApollo/alp"
+	"github.com/GreatGodApollo/als/database"
+	"io"
+	"io/ioutil"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	EventLicenseCreated             = "license.created"
+	EventLicenseInvalidated         = "license.invalidated"
+	EventLicenseSuspended           = "license.suspended"
+	EventLicenseResumed             = "license.resumed"
+	EventLicenseExpired             = "license.expired"
+	EventLicenseActivationsExceeded = "license.activations_exceeded"
+
+	SignatureHeader = "X-ALS-Signature"
+	TimestampHeader = "X-ALS-Timestamp"
+	EventHeader     = "X-ALS-Event"
+	DeliveryHeader  = "X-ALS-Delivery"
+)
+
+var Events = []string{
+	EventLicenseCreated,
+	EventLicenseInvalidated,
+	EventLicenseSuspended,
+	EventLicenseResumed,
+	EventLicenseExpired,
+	EventLicenseActivationsExceeded,
+}
+
+// Payload is the JSON body sent to webhook endpoints.
+type Payload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature sent in SignatureHeader, an HMAC-SHA256 over
+// the timestamp, a dot and the body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign, for use by receivers.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Enqueue stores a delivery of event for every webhook of the tenant that
+// subscribes to it. The dispatcher sends them later.
+func Enqueue(db *sql.DB, tenantID int, event string, data interface{}) error {
+	hooks, err := database.GetSubscribedWebhooks(db, tenantID, event)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	body, err := json.Marshal(Payload{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err = database.InsertDelivery(db, tenantID, hook.Id, event, string(body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Dispatcher struct {
+	DB          *sql.DB
+	Client      *http.Client
+	Interval    time.Duration
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	BatchSize   int
+}
+
+func NewDispatcher(db *sql.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Interval:    10 * time.Second,
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    6 * time.Hour,
+		BatchSize:   50,
+	}
+}
+
+// Run delivers due webhooks every Interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DeliverDue(ctx); err != nil {
+			log.Println("webhooks:", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes one attempt at every delivery that is currently due.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	deliveries, err := database.GetDueDeliveries(d.DB, d.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		hook, err := database.GetWebhook(d.DB, delivery.TenantId, delivery.WebhookId)
+		if err != nil {
+			if !errors.Is(err, database.ErrWebhookNonexistent) {
+				return err
+			}
+			err = database.MarkFailed(d.DB, delivery.TenantId, delivery.Id, err.Error(), nil)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err = d.Deliver(ctx, hook, delivery); err == nil {
+			err = database.MarkDelivered(d.DB, delivery.TenantId, delivery.Id)
+		} else {
+			err = database.MarkFailed(d.DB, delivery.TenantId, delivery.Id, err.Error(), d.nextAttempt(delivery.Attempts+1))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver makes a single signed POST of the delivery to the webhook.
+func (d *Dispatcher) Deliver(ctx context.Context, hook alp.Webhook, delivery alp.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "als-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.Id))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// nextAttempt returns when to retry after the given number of failed
+// attempts, or nil once the delivery should be given up on.
+func (d *Dispatcher) nextAttempt(attempts int) *time.Time {
+	if attempts >= d.MaxAttempts {
+		return nil
+	}
+	delay := d.BaseDelay << uint(attempts-1)
+	if delay <= 0 || delay > d.MaxDelay {
+		delay = d.MaxDelay
+	}
+	delay += time.Duration(mrand.Int63n(int64(delay)/5 + 1))
+	next := time.Now().Add(delay)
+	return &next
+}
+
+// LicenseData is the payload data of license events.
+type LicenseData struct {
+	Key       string     `json:"key"`
+	Product   string     `json:"product"`
+	Email     string     `json:"email"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Actor     string     `json:"actor,omitempty"`
+	// Fingerprint is the machine that was turned away, for
+	// license.activations_exceeded.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
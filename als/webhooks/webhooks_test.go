@@ -0,0 +1,211 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/GreatGodApollo/alp"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "s3cret"
+
+// receiver is a webhook endpoint that answers with the queued statuses in
+// turn, then 200, and records what it was sent.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, received{header: r.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) last() received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.requests[len(rc.requests)-1]
+}
+
+func newTestDispatcher() *Dispatcher {
+	d := NewDispatcher(nil)
+	d.MaxAttempts = 4
+	d.BaseDelay = time.Second
+	d.MaxDelay = 5 * time.Second
+	return d
+}
+
+func testDelivery(t *testing.T) alp.WebhookDelivery {
+	body, err := json.Marshal(Payload{
+		Event:     EventLicenseCreated,
+		CreatedAt: time.Now().UTC(),
+		Data:      LicenseData{Key: "AAAA-BBBB", Product: "prod", Email: "a@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alp.WebhookDelivery{Id: 7, WebhookId: 3, Event: EventLicenseCreated, Payload: string(body)}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"event":"license.created"}`)
+	sig := Sign(testSecret, "1700000000", body)
+
+	if !Verify(testSecret, "1700000000", body, sig) {
+		t.Fatal("signature did not verify")
+	}
+	if Verify("other", "1700000000", body, sig) {
+		t.Error("signature verified with the wrong secret")
+	}
+	if Verify(testSecret, "1700000001", body, sig) {
+		t.Error("signature verified with a different timestamp")
+	}
+	if Verify(testSecret, "1700000000", []byte(`{"event":"license.expired"}`), sig) {
+		t.Error("signature verified over a different body")
+	}
+}
+
+func TestDeliverSignsRequest(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	delivery := testDelivery(t)
+	err := newTestDispatcher().Deliver(context.Background(), alp.Webhook{Id: 3, URL: srv.URL, Secret: testSecret}, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := rc.last()
+	if string(got.body) != delivery.Payload {
+		t.Errorf("body = %s, want %s", got.body, delivery.Payload)
+	}
+	if got.header.Get(EventHeader) != EventLicenseCreated {
+		t.Errorf("%s = %q", EventHeader, got.header.Get(EventHeader))
+	}
+	if got.header.Get(DeliveryHeader) != strconv.Itoa(delivery.Id) {
+		t.Errorf("%s = %q", DeliveryHeader, got.header.Get(DeliveryHeader))
+	}
+	if got.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", got.header.Get("Content-Type"))
+	}
+	timestamp := got.header.Get(TimestampHeader)
+	if !Verify(testSecret, timestamp, got.body, got.header.Get(SignatureHeader)) {
+		t.Errorf("%s %q does not verify", SignatureHeader, got.header.Get(SignatureHeader))
+	}
+	if Verify("wrong", timestamp, got.body, got.header.Get(SignatureHeader)) {
+		t.Error("signature verified with the wrong secret")
+	}
+}
+
+func TestDeliverRetriesThenDeadLetters(t *testing.T) {
+	d := newTestDispatcher()
+	rc := &receiver{statuses: []int{500, 502, 503, 500}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	hook := alp.Webhook{Id: 3, URL: srv.URL, Secret: testSecret}
+	delivery := testDelivery(t)
+
+	var prev time.Duration
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		if err := d.Deliver(context.Background(), hook, delivery); err == nil {
+			t.Fatalf("attempt %d: 5xx response was treated as delivered", attempt)
+		}
+		delivery.Attempts++
+
+		next := d.nextAttempt(delivery.Attempts)
+		if attempt == d.MaxAttempts {
+			if next != nil {
+				t.Fatalf("attempt %d: retry scheduled past MaxAttempts", attempt)
+			}
+			break
+		}
+		if next == nil {
+			t.Fatalf("attempt %d: dead-lettered before MaxAttempts", attempt)
+		}
+
+		delay := time.Until(*next)
+		base := d.BaseDelay << uint(attempt-1)
+		if base > d.MaxDelay {
+			base = d.MaxDelay
+		}
+		// Jitter adds at most a fifth on top of the backoff.
+		if delay < base-time.Second || delay > base+base/5+time.Second {
+			t.Errorf("attempt %d: retry in %v, want about %v", attempt, delay, base)
+		}
+		if delay+time.Second < prev {
+			t.Errorf("attempt %d: backoff shrank from %v to %v", attempt, prev, delay)
+		}
+		prev = delay
+	}
+
+	if n := len(rc.requests); n != d.MaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", n, d.MaxAttempts)
+	}
+}
+
+func TestDeliverReplay(t *testing.T) {
+	d := newTestDispatcher()
+	rc := &receiver{statuses: []int{500}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	hook := alp.Webhook{Id: 3, URL: srv.URL, Secret: testSecret}
+	delivery := testDelivery(t)
+
+	if err := d.Deliver(context.Background(), hook, delivery); err == nil {
+		t.Fatal("5xx response was treated as delivered")
+	}
+	first := rc.last()
+
+	// A replay sends the stored payload again under the same delivery id,
+	// signed afresh.
+	delivery.Attempts = 0
+	if err := d.Deliver(context.Background(), hook, delivery); err != nil {
+		t.Fatal(err)
+	}
+	replay := rc.last()
+
+	if string(replay.body) != string(first.body) {
+		t.Errorf("replayed body = %s, want %s", replay.body, first.body)
+	}
+	if replay.header.Get(DeliveryHeader) != first.header.Get(DeliveryHeader) {
+		t.Errorf("replayed delivery id = %q, want %q", replay.header.Get(DeliveryHeader), first.header.Get(DeliveryHeader))
+	}
+	if !Verify(testSecret, replay.header.Get(TimestampHeader), replay.body, replay.header.Get(SignatureHeader)) {
+		t.Error("replayed signature does not verify")
+	}
+}
+
+func TestDeliverCancelled(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := newTestDispatcher().Deliver(ctx, alp.Webhook{URL: srv.URL, Secret: testSecret}, testDelivery(t))
+	if err == nil {
+		t.Fatal("delivery succeeded with a cancelled context")
+	}
+}